@@ -1,32 +1,170 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
+	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
+	_ "time/tzdata" // dates.timezone works where the system has no time zone database
 
+	"ts-www/build/internal/config"
 	"ts-www/build/internal/dev"
+	"ts-www/build/internal/search"
+	"ts-www/build/internal/site"
 	"ts-www/build/internal/static"
+	"ts-www/build/internal/utils"
 )
 
+// publishFlags registers the flags that include unpublished content.
+func publishFlags(fs *flag.FlagSet, opts *utils.PublishOptions) {
+	fs.BoolVar(&opts.Drafts, "drafts", false, "include content marked as draft")
+	fs.BoolVar(&opts.Future, "future", false, "include content with a publish date in the future")
+	fs.BoolVar(&opts.Expired, "expired", false, "include content past its expiry date")
+}
+
 func main() {
 	buildCmd := flag.NewFlagSet("build", flag.ExitOnError)
 	devCmd := flag.NewFlagSet("dev", flag.ExitOnError)
 
+	var buildOpts static.Options
+	buildCmd.BoolVar(&buildOpts.Incremental, "incremental", false, "re-render only pages whose inputs changed since the last build")
+	buildCmd.BoolVar(&buildOpts.Force, "force", false, "ignore the build manifest and re-render every page")
+	buildCmd.BoolVar(&buildOpts.Clean, "clean", false, "wipe the output directory and regenerate it from scratch")
+	publishFlags(buildCmd, &buildOpts.Publish)
+	reportFormat := buildCmd.String("report", "text", "format of the build report: text or json")
+	failOnWarnings := buildCmd.Bool("fail-on-warnings", false, "exit with a non-zero status if the build reports warnings")
+	var devOpts dev.Options
+	publishFlags(devCmd, &devOpts.Publish)
+	checkCmd := flag.NewFlagSet("check", flag.ExitOnError)
+	searchCmd := flag.NewFlagSet("search", flag.ExitOnError)
+	searchIndex := searchCmd.String("index", "", "path of the search index; default the one in the output directory")
+	searchLimit := searchCmd.Int("limit", 10, "maximum number of results, 0 for all")
+	searchJSON := searchCmd.Bool("json", false, "print the results as JSON")
+
 	if len(os.Args) < 2 {
-		log.Println("expected subcommand: 'build' or 'dev'")
+		log.Println("expected subcommand: 'build', 'dev', 'check' or 'search'")
 		os.Exit(1)
 	}
 
 	switch os.Args[1] {
 	case "build":
 		buildCmd.Parse(os.Args[2:])
-		static.BuildSite() // Call the build function
+		if *reportFormat != "text" && *reportFormat != "json" {
+			log.Printf("unknown report format %q: expected 'text' or 'json'", *reportFormat)
+			os.Exit(2)
+		}
+		report, _ := static.BuildSite(buildOpts) // Call the build function; its errors are in the report
+		if *reportFormat == "json" {
+			report.WriteJSON(os.Stdout)
+		} else {
+			report.WriteText(os.Stdout)
+		}
+		os.Exit(report.ExitCode(*failOnWarnings))
 	case "dev":
 		devCmd.Parse(os.Args[2:])
-		dev.StartServer() // Call the dev function
+		dev.StartServer(devOpts) // Call the dev function
+	case "check":
+		checkCmd.Parse(os.Args[2:])
+		ok, err := runCheck()
+		if err != nil {
+			log.Println(err)
+		}
+		if !ok {
+			os.Exit(1)
+		}
+	case "search":
+		searchCmd.Parse(os.Args[2:])
+		query := strings.Join(searchCmd.Args(), " ")
+		if query == "" {
+			log.Println("usage: search [flags] query")
+			os.Exit(2)
+		}
+		if err := runSearch(*searchIndex, query, *searchLimit, *searchJSON); err != nil {
+			log.Println(err)
+			os.Exit(1)
+		}
 	default:
-		log.Println("expected subcommand: 'build' or 'dev'")
+		log.Println("expected subcommand: 'build', 'dev', 'check' or 'search'")
 		os.Exit(1)
 	}
 }
+
+// runCheck validates the front matter of all content, published or not,
+// against the schemas of its collections, printing every problem. It reports
+// whether there were none.
+func runCheck() (bool, error) {
+	cfg, err := config.LoadConfig("./config.json")
+	if err != nil {
+		return false, fmt.Errorf("failed to load config: %w", err)
+	}
+	checked, problems, err := site.Check(cfg)
+	if err != nil {
+		return false, err
+	}
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+	if len(problems) > 0 {
+		fmt.Printf("Checked %d files, found %d problems\n", checked, len(problems))
+		return false, nil
+	}
+	fmt.Printf("Checked %d files, no problems\n", checked)
+	return true, nil
+}
+
+// runSearch queries a built search index and prints the ranked results.
+func runSearch(indexPath, query string, limit int, asJSON bool) error {
+	if indexPath == "" {
+		cfg, err := config.LoadConfig("./config.json")
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		permalink := cfg.Search.Permalink
+		if permalink == "" {
+			permalink = site.DefaultSearchPermalink
+		}
+		indexPath = filepath.Join(cfg.OutputPath, filepath.FromSlash(permalink))
+	}
+
+	f, err := os.Open(indexPath)
+	if err != nil {
+		return fmt.Errorf("failed to open search index, run build first: %w", err)
+	}
+	defer f.Close()
+	ix, err := search.Read(f)
+	if err != nil {
+		return fmt.Errorf("failed to read search index %s: %w", indexPath, err)
+	}
+
+	results := ix.Search(query, limit)
+	if asJSON {
+		type jsonResult struct {
+			Title      string  `json:"title"`
+			URL        string  `json:"url"`
+			Collection string  `json:"collection"`
+			Score      float64 `json:"score"`
+		}
+		out := []jsonResult{}
+		for _, r := range results {
+			out = append(out, jsonResult{Title: r.Document.Title, URL: r.Document.URL, Collection: r.Document.Collection, Score: r.Score})
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
+	}
+
+	if len(results) == 0 {
+		fmt.Printf("No results for %q\n", query)
+		return nil
+	}
+	for _, r := range results {
+		fmt.Printf("%6.2f  %s  %s\n", r.Score, r.Document.Title, r.Document.URL)
+		if r.Document.Description != "" {
+			fmt.Printf("        %s\n", r.Document.Description)
+		}
+	}
+	return nil
+}
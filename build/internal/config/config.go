@@ -2,7 +2,9 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
+	"time"
 )
 
 type Config struct {
@@ -13,6 +15,230 @@ type Config struct {
 	OutputPath      string `json:"outputPath"`
 	ThemeName       string `json:"themeName"`
 	DataPath        string `json:"dataPath"`
+	BaseURL         string `json:"baseURL"` // Scheme and host used for absolute URLs, e.g. in feeds
+
+	// Permalinks maps a collection name to its URL pattern, e.g.
+	// "/writing/:year/:slug/". See site.Permalink for the tokens.
+	Permalinks map[string]string `json:"permalinks"`
+
+	URLs URLConfig `json:"urls"`
+
+	// Taxonomies declares the front matter keys, such as "tags", whose
+	// values group content into terms with generated listing pages.
+	Taxonomies map[string]TaxonomyConfig `json:"taxonomies"`
+
+	Feeds FeedsConfig `json:"feeds"`
+
+	// Outputs selects the formats each collection is published in, by
+	// collection name. Collections not listed are written as HTML only.
+	Outputs map[string]OutputsConfig `json:"outputs"`
+
+	Markdown MarkdownConfig `json:"markdown"`
+
+	// Pagination splits the list page of a collection into pages of a fixed
+	// size, by collection name.
+	Pagination map[string]PaginationConfig `json:"pagination"`
+
+	Archives ArchivesConfig `json:"archives"`
+	Series   SeriesConfig   `json:"series"`
+
+	Sitemap SitemapConfig `json:"sitemap"`
+	Robots  RobotsConfig  `json:"robots"`
+	Search  SearchConfig  `json:"search"`
+	Related RelatedConfig `json:"related"`
+
+	Links   LinksConfig   `json:"links"`
+	Summary SummaryConfig `json:"summary"`
+	Dates   DatesConfig   `json:"dates"`
+
+	// Schemas declares the front matter each collection must have, by
+	// collection name. SchemaFile names a JSON file of more schemas in the
+	// same form, for sites that keep them out of the config.
+	Schemas    map[string]Schema `json:"schemas"`
+	SchemaFile string            `json:"schemaFile"`
+}
+
+// Schema declares the front matter of the content in one collection.
+type Schema struct {
+	Fields map[string]FieldSchema `json:"fields"`
+	Strict bool                   `json:"strict"` // Reject keys that aren't declared in Fields
+}
+
+// FieldSchemaTypes are the types a front matter field can be declared with.
+var FieldSchemaTypes = []string{"string", "int", "float", "bool", "date", "list", "map"}
+
+// FieldSchema declares one front matter key. An empty type accepts any value.
+type FieldSchema struct {
+	Type     string   `json:"type"`     // One of FieldSchemaTypes
+	Items    string   `json:"items"`    // Type of the items of a list
+	Required bool     `json:"required"` // The key must be given and not be empty
+	Values   []string `json:"values"`   // Allowed values, or items of a list, as written
+	Formats  []string `json:"formats"`  // Go time layouts a date may be written in; default those of content dates
+}
+
+// DatesConfig controls how content dates are read and shown. Front matter
+// dates are written as "2006-01-02", "2006-01-02 15:04", "2006-01-02T15:04:05"
+// or as RFC 3339 timestamps; only the last carry their own time zone.
+type DatesConfig struct {
+	Timezone string `json:"timezone"` // IANA name, e.g. "America/New_York", of dates without a zone; default UTC
+	Lastmod  string `json:"lastmod"`  // "git" takes the lastmod of content without one from its last commit
+
+	// Layouts names Go time layouts for the formatDate template function,
+	// e.g. "short": "Jan 2, 2006"
+	Layouts map[string]string `json:"layouts"`
+
+	location *time.Location
+}
+
+// Location returns the time zone of dates written without one.
+func (d DatesConfig) Location() *time.Location {
+	if d.location == nil {
+		return time.UTC
+	}
+	return d.location
+}
+
+// SummaryConfig controls the summaries and reading times computed for
+// content. A summary is the body up to a <!--more--> marker or, without one,
+// its first words as plain text.
+type SummaryConfig struct {
+	Words          int `json:"words"`          // Length of a summary without a marker; default 70
+	WordsPerMinute int `json:"wordsPerMinute"` // Reading speed; default 200
+}
+
+// LinksConfig controls internal links in content: [[target]] wiki links and
+// markdown links to ref:target. Targets are content paths such as
+// "writing/intro" or, when unique, a slug such as "intro", optionally
+// followed by #heading.
+type LinksConfig struct {
+	Unresolved string `json:"unresolved"` // "error" fails the build (default), "warn" renders the link text only
+}
+
+// PaginationConfig describes the paginated list of one collection. The first
+// page is the collection's list page, content/page/<collection>.md, or a
+// generated one when that doesn't exist.
+type PaginationConfig struct {
+	PageSize  int    `json:"pageSize"`
+	Permalink string `json:"permalink"` // Pattern for pages after the first; default "/:collection/page/:num"
+	Template  string `json:"template"`  // Template of a generated first page; default "page"
+}
+
+// SearchConfig controls the search index written during the build.
+type SearchConfig struct {
+	Enabled     bool     `json:"enabled"`
+	Permalink   string   `json:"permalink"`   // Where the index is written; default "/search.json"
+	Collections []string `json:"collections"` // Collections to index; default all of them
+}
+
+// RelatedConfig controls how related content is picked for each page. A
+// candidate's score adds the weight of every taxonomy term it shares with the
+// page, the collection weight if both are in the same collection, and the
+// text weight times the similarity of their bodies, from 0 to 1.
+type RelatedConfig struct {
+	Count      int                `json:"count"`      // Related items per page; 0 turns related content off
+	Taxonomies map[string]float64 `json:"taxonomies"` // Weight per shared term, by taxonomy; taxonomies not listed weigh 1
+	Collection float64            `json:"collection"`
+	Text       float64            `json:"text"`
+	Threshold  float64            `json:"threshold"` // Minimum score of a related item
+}
+
+// ArchivesConfig controls the date archives: an index of years, a page per
+// year and a page per month. Permalink patterns take the tokens :collection,
+// :year and :month; :collection is empty in the site-wide archive.
+type ArchivesConfig struct {
+	SiteWide       bool     `json:"siteWide"`       // Archive of all dated content
+	Collections    []string `json:"collections"`    // Collections with an archive of their own
+	Permalink      string   `json:"permalink"`      // Default "/:collection/archive"
+	YearPermalink  string   `json:"yearPermalink"`  // Default "/:collection/archive/:year"
+	MonthPermalink string   `json:"monthPermalink"` // Default "/:collection/archive/:year/:month"
+	Template       string   `json:"template"`       // Default "archive"
+}
+
+// SeriesConfig controls the overview pages generated for every series named
+// in the content's front matter.
+type SeriesConfig struct {
+	Permalink string `json:"permalink"` // Default "/series/:series"
+	Template  string `json:"template"`  // Default "series"
+}
+
+// SitemapConfig controls the generated sitemap.xml.
+type SitemapConfig struct {
+	Enabled bool     `json:"enabled"`
+	Exclude []string `json:"exclude"` // Permalink patterns left out, in path.Match syntax
+	MaxURLs int      `json:"maxURLs"` // URLs per file before splitting into a sitemap index, at most 50000
+}
+
+// RobotsConfig controls the generated robots.txt. It allows everything unless
+// rules say otherwise, and points crawlers at the sitemap when there is one.
+type RobotsConfig struct {
+	Enabled bool         `json:"enabled"`
+	Rules   []RobotsRule `json:"rules"`
+}
+
+// RobotsRule is one group of robots.txt directives.
+type RobotsRule struct {
+	UserAgent string   `json:"userAgent"` // Default "*"
+	Allow     []string `json:"allow"`
+	Disallow  []string `json:"disallow"`
+}
+
+// MarkdownConfig selects the markdown extensions content is rendered with.
+type MarkdownConfig struct {
+	// Any of tables, fencedCode, autolink, strikethrough, definitionLists,
+	// footnotes, headingIDs, hardLineBreaks, typographer, xhtml and taskLists.
+	// Leaving the list out keeps blackfriday's common set.
+	Extensions []string        `json:"extensions"`
+	TOC        TOCConfig       `json:"toc"`
+	Highlight  HighlightConfig `json:"highlight"`
+}
+
+// HighlightConfig controls the syntax highlighting of fenced code blocks.
+// Fences can override the line numbers and highlight lines themselves, as in
+// ```go {linenos=true hl_lines="2 4-5"}.
+type HighlightConfig struct {
+	Enabled     bool   `json:"enabled"`
+	Style       string `json:"style"`       // "github" (default) or "monokai"
+	Output      string `json:"output"`      // "classes" writes assets/css/syntax.css (default), "inline" styles every token
+	LineNumbers bool   `json:"lineNumbers"` // Number every block unless its fence says linenos=false
+}
+
+// TOCConfig bounds the heading levels listed in a page's table of contents.
+type TOCConfig struct {
+	StartLevel int `json:"startLevel"` // Default 2
+	EndLevel   int `json:"endLevel"`   // Default 4
+}
+
+// OutputsConfig lists the output formats of one collection.
+type OutputsConfig struct {
+	Page []string `json:"page"` // Formats of every page: "html" and "json"; default ["html"]
+	List []string `json:"list"` // Feed formats of the whole collection, overriding feeds.formats
+}
+
+// TaxonomyConfig controls the pages generated for a taxonomy. Permalinks may
+// use the :taxonomy and :term tokens; empty fields take the defaults below.
+type TaxonomyConfig struct {
+	Permalink     string `json:"permalink"`     // List of all terms, default "/:taxonomy"
+	TermPermalink string `json:"termPermalink"` // Content of one term, default "/:taxonomy/:term"
+	Template      string `json:"template"`      // Template of the term list, default "taxonomy"
+	TermTemplate  string `json:"termTemplate"`  // Template of a term page, default "term"
+}
+
+// URLConfig controls how pages are laid out in the output directory and the
+// form of the links generated for them. The hosting configuration, such as
+// vercel.json's trailingSlash, should agree with it.
+type URLConfig struct {
+	Pretty        bool   `json:"pretty"`        // Write writing/intro/index.html instead of writing/intro.html
+	TrailingSlash string `json:"trailingSlash"` // "always", "never", or empty to follow the permalink patterns
+}
+
+// FeedsConfig controls the syndication feeds generated from the content feed.
+// A site-wide feed is written for every format, plus one per listed
+// collection.
+type FeedsConfig struct {
+	Formats     []string `json:"formats"`     // Any of "rss", "atom" and "jsonfeed"
+	Collections []string `json:"collections"` // Collections that get a feed of their own
+	Content     string   `json:"content"`     // "full" for rendered bodies, otherwise summaries only
+	Limit       int      `json:"limit"`       // Maximum number of items per feed, 0 for no limit
 }
 
 func LoadConfig(path string) (*Config, error) {
@@ -26,5 +252,85 @@ func LoadConfig(path string) (*Config, error) {
 		return nil, err
 	}
 
+	switch config.URLs.TrailingSlash {
+	case "", "always", "never":
+	default:
+		return nil, fmt.Errorf("urls.trailingSlash must be \"always\", \"never\" or empty, got %q", config.URLs.TrailingSlash)
+	}
+
+	switch config.Links.Unresolved {
+	case "", "error", "warn":
+	default:
+		return nil, fmt.Errorf("links.unresolved must be \"error\", \"warn\" or empty, got %q", config.Links.Unresolved)
+	}
+
+	switch config.Dates.Lastmod {
+	case "", "git":
+	default:
+		return nil, fmt.Errorf("dates.lastmod must be \"git\" or empty, got %q", config.Dates.Lastmod)
+	}
+	if config.Dates.Timezone != "" {
+		if config.Dates.location, err = time.LoadLocation(config.Dates.Timezone); err != nil {
+			return nil, fmt.Errorf("dates.timezone: %w", err)
+		}
+	}
+
+	if err := loadSchemas(&config); err != nil {
+		return nil, err
+	}
+
 	return &config, nil
 }
+
+// loadSchemas adds the schemas of the schema file to the config and checks
+// that every schema declares known types.
+func loadSchemas(config *Config) error {
+	if config.SchemaFile != "" {
+		schemaFile, err := os.ReadFile(config.SchemaFile)
+		if err != nil {
+			return fmt.Errorf("schemaFile: %w", err)
+		}
+		var schemas map[string]Schema
+		if err := json.Unmarshal(schemaFile, &schemas); err != nil {
+			return fmt.Errorf("schemaFile %s: %w", config.SchemaFile, err)
+		}
+		if config.Schemas == nil {
+			config.Schemas = make(map[string]Schema)
+		}
+		for collection, schema := range schemas {
+			if _, ok := config.Schemas[collection]; ok {
+				return fmt.Errorf("schemaFile %s: the schema of %s is already in the config", config.SchemaFile, collection)
+			}
+			config.Schemas[collection] = schema
+		}
+	}
+
+	for collection, schema := range config.Schemas {
+		for key, field := range schema.Fields {
+			for _, t := range []string{field.Type, field.Items} {
+				if !validFieldType(t) {
+					return fmt.Errorf("schemas.%s.fields.%s: unknown type %q, expected one of %v", collection, key, t, FieldSchemaTypes)
+				}
+			}
+			if field.Items != "" && field.Type != "list" {
+				return fmt.Errorf("schemas.%s.fields.%s: items only apply to lists", collection, key)
+			}
+			if len(field.Formats) > 0 && field.Type != "date" && field.Items != "date" {
+				return fmt.Errorf("schemas.%s.fields.%s: formats only apply to dates", collection, key)
+			}
+		}
+	}
+	return nil
+}
+
+func validFieldType(t string) bool {
+	if t == "" {
+		return true
+	}
+	for _, known := range FieldSchemaTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
@@ -4,13 +4,16 @@ import (
 	"bytes"
 	"fmt"
 	"log"
+	"mime"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
-	"strings"
 	"ts-www/build/internal/config"
-	"ts-www/build/internal/models"
+	"ts-www/build/internal/frontmatter"
+	"ts-www/build/internal/markdown"
+	"ts-www/build/internal/site"
 	"ts-www/build/internal/utils"
 
 	"github.com/fsnotify/fsnotify"
@@ -114,16 +117,16 @@ func appendFrontmatter(filePath, collection string, contentDir string) error {
 		return err
 	}
 
-	// Extract frontmatter from base.md
-	start := bytes.Index(baseContent, []byte("---"))
-	if start == -1 {
-		return fmt.Errorf("frontmatter delimiter not found in base.md of collection: %s", collection)
+	// Extract frontmatter from base.md: everything before the body, in any
+	// of the formats content can use
+	_, body, err := frontmatter.Parse(baseContent)
+	if err != nil {
+		return fmt.Errorf("base.md of collection %s: %w", collection, err)
 	}
-	end := bytes.Index(baseContent[start+3:], []byte("---"))
-	if end == -1 {
-		return fmt.Errorf("closing frontmatter delimiter not found in base.md of collection: %s", collection)
+	frontmatterTemplate := string(bytes.TrimPrefix(baseContent[:len(baseContent)-len(body)], []byte("\xef\xbb\xbf")))
+	if frontmatterTemplate == "" {
+		return fmt.Errorf("frontmatter not found in base.md of collection: %s", collection)
 	}
-	frontmatterTemplate := string(baseContent[start : start+3+end+3])
 
 	// Read the existing content of the file being processed
 	existingContent, err := os.ReadFile(filePath)
@@ -158,7 +161,7 @@ func createTemplateForDir(dirName, templateDir string) {
 	<section>
 		<h2>{{.Page.Title}}</h2>
 		<article>
-		{{ .Page.Body | markDown }}
+		{{ .Page.HTML }}
 		</article>
 	</section>
 
@@ -175,59 +178,62 @@ func createTemplateForDir(dirName, templateDir string) {
 	}
 }
 
-func pageHandler(w http.ResponseWriter, r *http.Request, filePath string) {
+func pageHandler(w http.ResponseWriter, r *http.Request, opts Options) {
 	cfg, err := config.LoadConfig("./config.json")
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
+	// Dates from git would run git log on every request, for pages whose
+	// edits aren't committed yet anyway
+	cfg.Dates.Lastmod = ""
 	setCacheHeaders(w, 600)
 
-	log.Printf("Constructed file path: %s", filePath)
-
-	data, err := utils.LoadData(cfg.DataPath)
+	s, err := site.Load(cfg, opts.Publish)
+	if s == nil {
+		log.Println("Failed to load site:", err)
+		http.Error(w, "Internal Server Error", 500)
+		return
+	}
 	if err != nil {
-		log.Printf("Failed to load data: %v", err)
+		log.Printf("Error loading site: %v", err) // Broken pages elsewhere shouldn't stop this one
 	}
 
-	feed, err := utils.LoadFeed(cfg.ContentPath)
-	if err != nil {
-		log.Println("Failed to load feed:", err)
-		http.Error(w, "Internal Server Error", 500)
+	// Pages are routed by the same permalinks the build writes them to
+	route := s.RouteByURL(r.URL.Path)
+	if route == nil {
+		log.Printf("Error loading page: no page at %s", r.URL.Path)
+		http.Error(w, "Page not found", http.StatusNotFound)
 		return
 	}
 
-	p, err := utils.LoadPageFromDirectory(cfg.ContentPath, filePath)
-	if err != nil {
-		log.Printf("Error loading page: %v", err) // Log the error for debugging
-		http.Error(w, "Page not found", http.StatusNotFound)
+	// Redirect to the canonical form of the URL, as the production host does
+	// for .html extensions and trailing slashes
+	if r.URL.Path != route.Permalink {
+		target := route.Permalink
+		if r.URL.RawQuery != "" {
+			target += "?" + r.URL.RawQuery
+		}
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
 		return
 	}
 
 	// Generate the OG Image URL
-	// ogImageFileName := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath)) + "-og-image.png"
+	// ogImageFileName := strings.TrimSuffix(filepath.Base(route.Source), filepath.Ext(route.Source)) + "-og-image.png"
 	// ogImageUrl := "/public/og-image/" + ogImageFileName
 	// p.OGImageURL = ogImageUrl
 
-	collection := filepath.Base(filepath.Dir(filePath))
-
-	templateData := struct {
-		Page *models.Content
-		Data map[string]interface{}
-		Feed []models.Content
-	}{
-		Page: p,
-		Data: data,
-		Feed: feed,
-	}
-
-	tmplName := collection
-	tmpl := utils.Templates.Lookup(tmplName + ".html")
-	if tmpl == nil {
-		log.Printf("Template %s.html not found, using default site.html", tmplName)
-		tmpl = utils.Templates.Lookup("site.html")
+	if route.Render != nil {
+		if contentType := mime.TypeByExtension(path.Ext(route.Permalink)); contentType != "" {
+			w.Header().Set("Content-Type", contentType)
+		}
+		if err := route.Render(w); err != nil {
+			log.Printf("Error rendering %s: %v", route.Permalink, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
 	}
 
-	utils.RenderTemplateDev(w, tmplName, templateData)
+	utils.RenderTemplateDev(w, route.Template, route.Data)
 }
 
 var validPath = regexp.MustCompile("^/([a-zA-Z0-9]+)$")
@@ -248,7 +254,12 @@ func setCacheHeaders(w http.ResponseWriter, maxAge int) {
 	w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", maxAge))
 }
 
-func StartServer() {
+// Options control which content the development server renders.
+type Options struct {
+	Publish utils.PublishOptions
+}
+
+func StartServer(opts Options) {
 	// Load configuration
 	cfg, err := config.LoadConfig("./config.json")
 	if err != nil {
@@ -268,8 +279,11 @@ func StartServer() {
 	if err != nil {
 		log.Fatalf("Failed to copy theme CSS to assets directory: %v", err)
 	}
+	if err := markdown.WriteStyleSheet(cfg.Markdown, "assets"); err != nil {
+		log.Fatalf("Failed to write syntax highlighting CSS: %v", err)
+	}
 
-	err = utils.LoadTemplates()
+	err = utils.LoadTemplates(site.FuncMap(cfg))
 	if err != nil {
 		log.Fatalf("Failed to load templates: %v", err)
 	}
@@ -291,20 +305,7 @@ func StartServer() {
 	go watchForNewMarkdownFiles("content")
 
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		switch {
-		case r.URL.Path == "/":
-			// Serve 'index.md' from the 'page' directory for the root path
-			pageHandler(w, r, "page/index.md")
-		case !strings.Contains(r.URL.Path[1:], "/"):
-			// Handle 'page' collection routes without the 'page' prefix in the URL
-			// For example, "/about" will serve "page/about.md"
-			strippedPath := strings.TrimPrefix(r.URL.Path, "/")
-			pageHandler(w, r, fmt.Sprintf("page/%s.md", strippedPath))
-		default:
-			// Handle other collection routes
-			// For example, "/post/post1" will serve "post/post1.md"
-			pageHandler(w, r, r.URL.Path[1:]+".md") // [1:] to remove the leading '/'
-		}
+		pageHandler(w, r, opts)
 	})
 
 	fs := http.FileServer(http.Dir("src/public"))
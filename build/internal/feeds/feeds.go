@@ -0,0 +1,187 @@
+package feeds
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+// Channel is a syndication feed independent of its output format.
+type Channel struct {
+	Title       string
+	Description string
+	Link        string // Absolute URL of the page the feed syndicates
+	FeedURL     string // Absolute URL of the feed itself
+	Updated     time.Time
+	Items       []Item
+}
+
+// Item is a single entry of a feed.
+type Item struct {
+	ID        string // Stable absolute URL identifying the item
+	Title     string
+	Link      string
+	Published time.Time
+	Updated   time.Time // Last modification, when it is later than Published
+	Summary   string
+	Content   string // Rendered HTML, empty when the feed only carries summaries
+	Tags      []string
+}
+
+type rss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	AtomLink      atomLink  `xml:"atom:link"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        rssGUID  `xml:"guid"`
+	PubDate     string   `xml:"pubDate,omitempty"`
+	Categories  []string `xml:"category"`
+	Description string   `xml:"description"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// WriteRSS writes the channel as an RSS 2.0 document. Items carrying content
+// publish it as their description, others their summary.
+func WriteRSS(w io.Writer, c Channel) error {
+	doc := rss{
+		Version: "2.0",
+		Atom:    "http://www.w3.org/2005/Atom",
+		Channel: rssChannel{
+			Title:       c.Title,
+			Link:        c.Link,
+			Description: c.Description,
+			AtomLink:    atomLink{Href: c.FeedURL, Rel: "self", Type: "application/rss+xml"},
+		},
+	}
+	if !c.Updated.IsZero() {
+		doc.Channel.LastBuildDate = c.Updated.Format(time.RFC1123Z)
+	}
+	for _, item := range c.Items {
+		entry := rssItem{
+			Title:       item.Title,
+			Link:        item.Link,
+			GUID:        rssGUID{IsPermaLink: item.ID == item.Link, Value: item.ID},
+			Categories:  item.Tags,
+			Description: item.Summary,
+		}
+		if item.Content != "" {
+			entry.Description = item.Content
+		}
+		if !item.Published.IsZero() {
+			entry.PubDate = item.Published.Format(time.RFC1123Z)
+		}
+		doc.Channel.Items = append(doc.Channel.Items, entry)
+	}
+	return writeXML(w, doc)
+}
+
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"feed"`
+	NS       string      `xml:"xmlns,attr"`
+	ID       string      `xml:"id"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	Updated  string      `xml:"updated"`
+	Links    []atomLink  `xml:"link"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published,omitempty"`
+	Link       atomLink       `xml:"link"`
+	Categories []atomCategory `xml:"category"`
+	Summary    *atomText      `xml:"summary,omitempty"`
+	Content    *atomText      `xml:"content,omitempty"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// WriteAtom writes the channel as an Atom 1.0 document.
+func WriteAtom(w io.Writer, c Channel) error {
+	doc := atomFeed{
+		NS:       "http://www.w3.org/2005/Atom",
+		ID:       c.FeedURL,
+		Title:    c.Title,
+		Subtitle: c.Description,
+		Updated:  c.Updated.Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: c.FeedURL, Rel: "self", Type: "application/atom+xml"},
+			{Href: c.Link, Rel: "alternate", Type: "text/html"},
+		},
+	}
+	for _, item := range c.Items {
+		// Atom requires every entry to have an updated date, so items with
+		// neither date take the feed's
+		entry := atomEntry{
+			ID:      item.ID,
+			Title:   item.Title,
+			Updated: doc.Updated,
+			Link:    atomLink{Href: item.Link, Rel: "alternate"},
+		}
+		if !item.Published.IsZero() {
+			entry.Published = item.Published.Format(time.RFC3339)
+			entry.Updated = entry.Published
+		}
+		if item.Updated.After(item.Published) {
+			entry.Updated = item.Updated.Format(time.RFC3339)
+		}
+		for _, tag := range item.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+		}
+		if item.Summary != "" {
+			entry.Summary = &atomText{Type: "text", Value: item.Summary}
+		}
+		if item.Content != "" {
+			entry.Content = &atomText{Type: "html", Value: item.Content}
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+	return writeXML(w, doc)
+}
+
+func writeXML(w io.Writer, doc interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,40 @@
+package feeds
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteAtomEntryUpdated(t *testing.T) {
+	published := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	c := Channel{
+		Title:   "Writing",
+		Link:    "https://example.com/writing",
+		FeedURL: "https://example.com/writing/atom.xml",
+		Updated: time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC),
+		Items: []Item{
+			{ID: "a", Title: "Edited", Published: published, Updated: published.AddDate(0, 1, 0)},
+			{ID: "b", Title: "Published", Published: published},
+			{ID: "c", Title: "Undated"},
+		},
+	}
+	var buf bytes.Buffer
+	if err := WriteAtom(&buf, c); err != nil {
+		t.Fatal(err)
+	}
+	entries := strings.Split(buf.String(), "<entry>")[1:]
+	want := []string{"2024-04-01T09:00:00Z", "2024-03-01T09:00:00Z", "2024-05-02T00:00:00Z"}
+	if len(entries) != len(want) {
+		t.Fatalf("%d entries, want %d:\n%s", len(entries), len(want), buf.String())
+	}
+	for i, entry := range entries {
+		if !strings.Contains(entry, "<updated>"+want[i]+"</updated>") {
+			t.Errorf("entry %d = %s, want updated %s", i, entry, want[i])
+		}
+	}
+	if strings.Contains(entries[2], "<published>") {
+		t.Errorf("undated entry has a published date: %s", entries[2])
+	}
+}
@@ -0,0 +1,71 @@
+package feeds
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	FeedURL     string         `json:"feed_url"`
+	Description string         `json:"description,omitempty"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string   `json:"id"`
+	URL           string   `json:"url"`
+	ExternalURL   string   `json:"external_url,omitempty"`
+	Title         string   `json:"title"`
+	ContentHTML   string   `json:"content_html,omitempty"`
+	ContentText   string   `json:"content_text,omitempty"`
+	Summary       string   `json:"summary,omitempty"`
+	DatePublished string   `json:"date_published,omitempty"`
+	DateModified  string   `json:"date_modified,omitempty"`
+	Tags          []string `json:"tags,omitempty"`
+}
+
+// WriteJSONFeed writes the channel as a JSON Feed 1.1 document. The format
+// requires every item to carry content, so items without any fall back to
+// their summary as plain text.
+func WriteJSONFeed(w io.Writer, c Channel) error {
+	doc := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       c.Title,
+		HomePageURL: c.Link,
+		FeedURL:     c.FeedURL,
+		Description: c.Description,
+		Items:       []jsonFeedItem{},
+	}
+	for _, item := range c.Items {
+		entry := jsonFeedItem{
+			ID:          item.ID,
+			URL:         item.ID,
+			Title:       item.Title,
+			ContentHTML: item.Content,
+			Summary:     item.Summary,
+			Tags:        item.Tags,
+		}
+		if item.Link != item.ID {
+			entry.ExternalURL = item.Link
+		}
+		if item.Content == "" {
+			entry.ContentText = item.Summary
+		}
+		if !item.Published.IsZero() {
+			entry.DatePublished = item.Published.Format(time.RFC3339)
+		}
+		if item.Updated.After(item.Published) {
+			entry.DateModified = item.Updated.Format(time.RFC3339)
+		}
+		doc.Items = append(doc.Items, entry)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(doc)
+}
@@ -0,0 +1,196 @@
+// Package frontmatter splits content files into their front matter and body.
+//
+// Front matter has to start on the first line of a file and comes in one of
+// three formats, told apart by its first line:
+//
+//	---            +++            {
+//	title: YAML    title = "TOML"   "title": "JSON"
+//	---            +++            }
+//
+// Values are normalised across formats: maps have string keys, whole numbers
+// are ints, other numbers float64 and dates stay strings as they were written.
+package frontmatter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-yaml/yaml"
+)
+
+// Error is front matter that could not be parsed. Lines and columns count
+// from 1 at the start of the file; a column of 0 means it is unknown.
+type Error struct {
+	Format string
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *Error) Error() string {
+	if e.Column > 0 {
+		return fmt.Sprintf("%s front matter: line %d, column %d: %v", e.Format, e.Line, e.Column, e.Err)
+	}
+	return fmt.Sprintf("%s front matter: line %d: %v", e.Format, e.Line, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+var bom = []byte("\xef\xbb\xbf")
+
+// Parse splits content into its front matter and body. Content without front
+// matter has an empty map and is all body. The body starts right after the
+// front matter, with the rest of its closing line.
+func Parse(content []byte) (map[string]interface{}, []byte, error) {
+	content = bytes.TrimPrefix(content, bom)
+	switch {
+	case hasDelimiter(content, "---"):
+		return parseDelimited(content, "---", "YAML", parseYAML)
+	case hasDelimiter(content, "+++"):
+		return parseDelimited(content, "+++", "TOML", parseTOML)
+	case len(content) > 0 && content[0] == '{':
+		return parseJSON(content)
+	}
+	return map[string]interface{}{}, content, nil
+}
+
+// hasDelimiter reports whether the first line of content is the delimiter,
+// allowing trailing spaces.
+func hasDelimiter(content []byte, delimiter string) bool {
+	line, _, _ := bytes.Cut(content, []byte("\n"))
+	return string(bytes.TrimRight(line, " \t\r")) == delimiter
+}
+
+// parseDelimited parses front matter between two delimiter lines. The text
+// handed to parse starts at the end of the first delimiter, so its line
+// numbers are the lines of the file.
+func parseDelimited(content []byte, delimiter, format string, parse func([]byte) (map[string]interface{}, error)) (map[string]interface{}, []byte, error) {
+	start := len(delimiter)
+	end := -1
+	for pos := bytes.IndexByte(content, '\n') + 1; pos > 0 && pos < len(content); {
+		lineEnd := len(content)
+		if next := bytes.IndexByte(content[pos:], '\n'); next >= 0 {
+			lineEnd = pos + next
+		}
+		if string(bytes.TrimRight(content[pos:lineEnd], " \t\r")) == delimiter {
+			end = pos
+			break
+		}
+		pos = lineEnd + 1
+	}
+	if end < 0 {
+		return nil, nil, &Error{Format: format, Line: 1, Err: fmt.Errorf("the opening %s is never closed", delimiter)}
+	}
+
+	fm, err := parse(content[start:end])
+	if err != nil {
+		var fmErr *Error
+		if errors.As(err, &fmErr) {
+			fmErr.Format = format
+			return nil, nil, fmErr
+		}
+		return nil, nil, &Error{Format: format, Line: 1, Err: err}
+	}
+	return fm, content[end+len(delimiter):], nil
+}
+
+var yamlLinePattern = regexp.MustCompile(`line (\d+): (.*)`)
+
+func parseYAML(text []byte) (map[string]interface{}, error) {
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(text, &raw); err != nil {
+		// yaml reports positions as "yaml: line 3: message", counting lines
+		// from 0 for syntax errors and from 1 for type errors
+		if m := yamlLinePattern.FindStringSubmatch(err.Error()); m != nil {
+			line, _ := strconv.Atoi(m[1])
+			if strings.HasPrefix(err.Error(), "yaml: line ") {
+				line++
+			}
+			return nil, &Error{Line: line, Err: errors.New(m[2])}
+		}
+		return nil, err
+	}
+	fm := make(map[string]interface{}, len(raw))
+	for key, value := range raw {
+		fm[key] = normalize(value)
+	}
+	return fm, nil
+}
+
+func parseJSON(content []byte) (map[string]interface{}, []byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(content))
+	dec.UseNumber()
+	var raw map[string]interface{}
+	if err := dec.Decode(&raw); err != nil {
+		var offset int64
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		switch {
+		case errors.As(err, &syntaxErr):
+			offset = syntaxErr.Offset
+		case errors.As(err, &typeErr):
+			offset = typeErr.Offset
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			offset = int64(len(content))
+			err = errors.New("the opening { is never closed")
+		}
+		line, column := position(content, int(offset))
+		return nil, nil, &Error{Format: "JSON", Line: line, Column: column, Err: err}
+	}
+	fm := make(map[string]interface{}, len(raw))
+	for key, value := range raw {
+		fm[key] = normalize(value)
+	}
+	return fm, content[dec.InputOffset():], nil
+}
+
+// position returns the line and column of an offset into text.
+func position(text []byte, offset int) (line, column int) {
+	if offset > len(text) {
+		offset = len(text)
+	}
+	line = 1 + bytes.Count(text[:offset], []byte("\n"))
+	column = offset - bytes.LastIndexByte(text[:offset], '\n')
+	return line, column
+}
+
+// normalize converts decoded values to the types every format shares.
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[fmt.Sprint(key)] = normalize(item)
+		}
+		return m
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = normalize(item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalize(item)
+		}
+		return v
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return int(n)
+		}
+		f, _ := v.Float64()
+		return f
+	case int64:
+		return int(v)
+	case uint64:
+		return int(v)
+	}
+	return value
+}
@@ -0,0 +1,170 @@
+package frontmatter
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    map[string]interface{}
+		body    string
+	}{
+		{
+			name:    "yaml",
+			content: "---\ntitle: Intro\ndate: 2024-01-02\ntags: [go, meta]\n---\nbody\n",
+			want:    map[string]interface{}{"title": "Intro", "date": "2024-01-02", "tags": []interface{}{"go", "meta"}},
+			body:    "\nbody\n",
+		},
+		{
+			name:    "yaml with a horizontal rule in the body",
+			content: "---\ntitle: Intro\n---\nabove\n\n---\n\nbelow\n",
+			want:    map[string]interface{}{"title": "Intro"},
+			body:    "\nabove\n\n---\n\nbelow\n",
+		},
+		{
+			name:    "toml",
+			content: "+++\ntitle = \"Intro\"\ndate = 2024-01-02\ncount = 3\n[meta]\nrank = 2.5\n+++\nbody\n",
+			want: map[string]interface{}{
+				"title": "Intro", "date": "2024-01-02", "count": 3,
+				"meta": map[string]interface{}{"rank": 2.5},
+			},
+			body: "\nbody\n",
+		},
+		{
+			name:    "toml tables in arrays of tables",
+			content: "+++\n[[a]]\n[a.b]\nx = 1\n[[a]]\n[a.b]\nx = 2\n+++\n",
+			want: map[string]interface{}{"a": []interface{}{
+				map[string]interface{}{"b": map[string]interface{}{"x": 1}},
+				map[string]interface{}{"b": map[string]interface{}{"x": 2}},
+			}},
+			body: "\n",
+		},
+		{
+			name:    "toml timestamps",
+			content: "+++\ndate = 2024-01-02T10:30:00+02:00\nlocal = 2024-01-02T10:30:00\n+++\n",
+			want:    map[string]interface{}{"date": "2024-01-02T10:30:00+02:00", "local": "2024-01-02T10:30:00"},
+			body:    "\n",
+		},
+		{
+			name:    "json",
+			content: "{\n  \"title\": \"Intro\",\n  \"count\": 3,\n  \"rank\": 2.5\n}\nbody\n",
+			want:    map[string]interface{}{"title": "Intro", "count": 3, "rank": 2.5},
+			body:    "\nbody\n",
+		},
+		{
+			name:    "none",
+			content: "just a body\n",
+			want:    map[string]interface{}{},
+			body:    "just a body\n",
+		},
+		{
+			name:    "byte order mark",
+			content: "\xef\xbb\xbf---\ntitle: Intro\n---\n",
+			want:    map[string]interface{}{"title": "Intro"},
+			body:    "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fm, body, err := Parse([]byte(tt.content))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if !reflect.DeepEqual(fm, tt.want) {
+				t.Errorf("front matter = %#v, want %#v", fm, tt.want)
+			}
+			if string(body) != tt.body {
+				t.Errorf("body = %q, want %q", body, tt.body)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		format  string
+		line    int
+		message string
+	}{
+		{"yaml syntax", "---\ntitle: Intro\ntags: [go\n---\n", "YAML", 4, ""},
+		{"yaml never closed", "---\ntitle: Intro\n", "YAML", 1, "never closed"},
+		{"toml table defined twice", "+++\n[a]\nx = 1\n[a]\ny = 2\n+++\n", "TOML", 4, ""},
+		{"toml table defined by a dotted key", "+++\na.b = 1\n[a]\nc = 2\n+++\n", "TOML", 3, ""},
+		{"toml key defined twice", "+++\ntitle = \"a\"\ntitle = \"b\"\n+++\n", "TOML", 3, ""},
+		{"toml bad value", "+++\ntitle = Intro\n+++\n", "TOML", 2, ""},
+		{"json syntax", "{\n  \"title\": \"Intro\",\n  \"tags\": [go]\n}\n", "JSON", 3, ""},
+		{"json never closed", "{\n  \"title\": \"Intro\"\n", "JSON", 3, "never closed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := Parse([]byte(tt.content))
+			var fmErr *Error
+			if !errors.As(err, &fmErr) {
+				t.Fatalf("Parse error = %v, want an *Error", err)
+			}
+			if fmErr.Format != tt.format || fmErr.Line != tt.line {
+				t.Errorf("error %q is %s at line %d, want %s at line %d", err, fmErr.Format, fmErr.Line, tt.format, tt.line)
+			}
+			if !strings.Contains(err.Error(), tt.message) {
+				t.Errorf("error %q doesn't mention %q", err, tt.message)
+			}
+		})
+	}
+}
+
+func TestTOMLTables(t *testing.T) {
+	tests := []struct {
+		name  string
+		toml  string
+		valid bool
+	}{
+		{"same subtable in each array element", "[[a]]\n[a.b]\nx = 1\n[[a]]\n[a.b]\nx = 2", true},
+		{"subtable twice in one array element", "[[a]]\n[a.b]\nx = 1\n[a.b]\nx = 2", false},
+		{"parent after a subtable", "[a.b]\nx = 1\n[a]\ny = 2", true},
+		{"header below a dotted table", "[fruit]\napple.color = \"red\"\n[fruit.apple.texture]\nsmooth = true", true},
+		{"header reopening a dotted table", "a.b = 1\n[a]\nc = 2", false},
+		{"header reopening a nested dotted table", "[fruit]\napple.color = \"red\"\n[fruit.apple]\nsize = 1", false},
+		{"dotted keys extending a header table", "[a.b]\nx = 1\n[a]\nb.y = 2", false},
+		{"dotted keys in one table", "a.b = 1\na.c = 2", true},
+		{"header extending an inline table", "a = {b = 1}\n[a.c]\nd = 2", false},
+		{"dotted keys extending an inline table", "a = {b = 1}\na.c = 2", false},
+		{"array of tables over a value", "a = [1, 2]\n[[a]]\nb = 1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseTOML([]byte("\n" + tt.toml + "\n"))
+			if tt.valid && err != nil {
+				t.Errorf("parseTOML: %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Error("parseTOML accepted invalid TOML")
+			}
+		})
+	}
+}
+
+func TestKeyLines(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    map[string]int
+	}{
+		{"yaml", "---\ntitle: a\n\"quoted key\": b\nlist:\n  - x\n---\n", map[string]int{"title": 2, "quoted key": 3, "list": 4}},
+		{"toml", "+++\ntitle = \"a\"\nmeta.rank = 1\n[extra]\nx = 1\n+++\n", map[string]int{"title": 2, "meta": 3, "extra": 4}},
+		{"json", "{\n  \"title\": \"a\",\n  \"meta\": {\"rank\": 1},\n  \"draft\": false\n}\n", map[string]int{"title": 2, "meta": 3, "draft": 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := KeyLines([]byte(tt.content)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("KeyLines = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
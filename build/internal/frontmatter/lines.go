@@ -0,0 +1,141 @@
+package frontmatter
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// KeyLines returns the line each top-level front matter key is written on,
+// counted from 1 at the start of the file, for pointing at a key in messages
+// about its value. Front matter that doesn't parse may have lines missing.
+func KeyLines(content []byte) map[string]int {
+	content = bytes.TrimPrefix(content, bom)
+	lines := make(map[string]int)
+	switch {
+	case hasDelimiter(content, "---"):
+		scanKeys(content, "---", lines, yamlKey)
+	case hasDelimiter(content, "+++"):
+		// Keys after a table header belong to the table
+		inTable := false
+		scanKeys(content, "+++", lines, func(line string) string {
+			if strings.HasPrefix(strings.TrimSpace(line), "[") {
+				inTable = true
+			} else if inTable {
+				return ""
+			}
+			return tomlKey(line)
+		})
+	case len(content) > 0 && content[0] == '{':
+		jsonKeyLines(content, lines)
+	}
+	return lines
+}
+
+// scanKeys records the keys found by key on the lines between two
+// delimiters, keeping the first line of keys written more than once.
+func scanKeys(content []byte, delimiter string, lines map[string]int, key func(string) string) {
+	for i, text := range strings.Split(string(content), "\n") {
+		if i == 0 {
+			continue
+		}
+		if strings.TrimRight(text, " \t\r") == delimiter {
+			return
+		}
+		if name := key(text); name != "" {
+			if _, ok := lines[name]; !ok {
+				lines[name] = i + 1
+			}
+		}
+	}
+}
+
+// yamlKey returns the key of a top-level "key: value" line.
+func yamlKey(line string) string {
+	if line == "" || strings.ContainsRune(" \t#-", rune(line[0])) {
+		return ""
+	}
+	if line[0] == '"' || line[0] == '\'' {
+		end := strings.IndexByte(line[1:], line[0])
+		if end < 0 {
+			return ""
+		}
+		return line[1 : end+1]
+	}
+	name, _, found := strings.Cut(line, ":")
+	if !found {
+		return ""
+	}
+	return strings.TrimSpace(name)
+}
+
+// tomlKey returns the first part of the key of a "key = value" line or a
+// table header, which is the top-level key the line belongs to.
+func tomlKey(line string) string {
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, "[") {
+		line = strings.TrimLeft(line, "[ \t")
+	} else if eq := strings.IndexByte(line, '='); eq > 0 {
+		line = line[:eq]
+	} else {
+		return ""
+	}
+	if line != "" && (line[0] == '"' || line[0] == '\'') {
+		end := strings.IndexByte(line[1:], line[0])
+		if end < 0 {
+			return ""
+		}
+		if line[0] == '"' {
+			if s, err := strconv.Unquote(line[:end+2]); err == nil {
+				return s
+			}
+		}
+		return line[1 : end+1]
+	}
+	end := strings.IndexAny(line, ".] \t")
+	if end < 0 {
+		end = len(line)
+	}
+	return line[:end]
+}
+
+// jsonKeyLines records the lines of the keys of a JSON object.
+func jsonKeyLines(content []byte, lines map[string]int) {
+	dec := json.NewDecoder(bytes.NewReader(content))
+	depth := 0
+	expectKey := false
+	for {
+		offset := dec.InputOffset()
+		tok, err := dec.Token()
+		if err != nil {
+			return
+		}
+		switch t := tok.(type) {
+		case json.Delim:
+			switch t {
+			case '{', '[':
+				depth++
+				expectKey = depth == 1
+				continue
+			case '}', ']':
+				depth--
+				if depth == 0 {
+					return
+				}
+			}
+		case string:
+			if depth == 1 && expectKey {
+				// The offset is before the separator ahead of the key
+				start := int(offset) + bytes.IndexByte(content[offset:], '"')
+				line, _ := position(content, start)
+				if _, ok := lines[t]; !ok {
+					lines[t] = line
+				}
+				expectKey = false
+				continue
+			}
+		}
+		expectKey = depth == 1
+	}
+}
@@ -0,0 +1,551 @@
+package frontmatter
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// parseTOML parses the subset of TOML front matter needs: key/value pairs
+// with bare, quoted and dotted keys, tables, arrays of tables, strings of all
+// four kinds, integers, floats, booleans, arrays and inline tables. Dates and
+// times are kept as the strings they were written as, like YAML dates.
+func parseTOML(text []byte) (map[string]interface{}, error) {
+	p := &tomlParser{src: string(text), root: make(map[string]interface{})}
+	p.table = p.root
+	if err := p.parse(); err != nil {
+		line, column := position(text, p.pos)
+		return nil, &Error{Line: line, Column: column, Err: err}
+	}
+	return p.root, nil
+}
+
+type tomlParser struct {
+	src       string
+	pos       int
+	root      map[string]interface{}
+	table     map[string]interface{} // Table key/value pairs are added to
+	tablePath string                 // Path of table
+
+	// How tables were defined, by path: a path names every key from the root
+	// and, in arrays of tables, the index of the element, so the tables of
+	// different elements are told apart
+	headed map[string]bool // By a header, which can't be repeated
+	dotted map[string]bool // By dotted keys, which headers can't open again
+	inline map[string]bool // As inline tables or arrays, which can't be extended at all
+}
+
+// tomlPath returns the path of key in the table at path.
+func tomlPath(path, key string) string {
+	return path + "." + strconv.Quote(key)
+}
+
+func (p *tomlParser) parse() error {
+	p.headed = make(map[string]bool)
+	p.dotted = make(map[string]bool)
+	p.inline = make(map[string]bool)
+	for {
+		p.skipSpace()
+		if p.eof() {
+			return nil
+		}
+		switch c := p.src[p.pos]; {
+		case c == '#' || c == '\n' || c == '\r':
+			p.skipComment()
+			p.skipNewline()
+			continue
+		case strings.HasPrefix(p.src[p.pos:], "[["):
+			if err := p.header(true); err != nil {
+				return err
+			}
+		case c == '[':
+			if err := p.header(false); err != nil {
+				return err
+			}
+		default:
+			if err := p.keyValue(p.table, p.tablePath); err != nil {
+				return err
+			}
+		}
+		if err := p.endOfLine(); err != nil {
+			return err
+		}
+	}
+}
+
+// header parses a [table] or [[array of tables]] header and makes its table
+// the one key/value pairs are added to.
+func (p *tomlParser) header(array bool) error {
+	opening, closing := "[", "]"
+	if array {
+		opening, closing = "[[", "]]"
+	}
+	p.pos += len(opening)
+	p.skipSpace()
+	start := p.pos
+	keys, err := p.key()
+	if err != nil {
+		return err
+	}
+	p.skipSpace()
+	if !strings.HasPrefix(p.src[p.pos:], closing) {
+		return fmt.Errorf("expected %s after table name", closing)
+	}
+	p.pos += len(closing)
+	name := strings.Join(keys, ".")
+
+	parent, parentPath, err := p.descend(p.root, "", keys[:len(keys)-1], false, start)
+	if err != nil {
+		return err
+	}
+	last := keys[len(keys)-1]
+	path := tomlPath(parentPath, last)
+	if array {
+		existing, ok := parent[last]
+		list, isList := existing.([]interface{})
+		if ok && (!isList || p.inline[path]) {
+			return fmt.Errorf("%s is already defined as a value", name)
+		}
+		table := make(map[string]interface{})
+		parent[last] = append(list, table)
+		p.table, p.tablePath = table, fmt.Sprintf("%s[%d]", path, len(list))
+		return nil
+	}
+
+	switch {
+	case p.headed[path]:
+		return fmt.Errorf("table %s is defined twice", name)
+	case p.dotted[path]:
+		return fmt.Errorf("table %s is already defined by dotted keys", name)
+	case p.inline[path]:
+		return fmt.Errorf("table %s is already defined inline", name)
+	}
+	p.headed[path] = true
+	switch existing := parent[last].(type) {
+	case nil:
+		table := make(map[string]interface{})
+		parent[last] = table
+		p.table = table
+	case map[string]interface{}:
+		p.table = existing // Created implicitly by a deeper header
+	default:
+		return fmt.Errorf("%s is already defined as a value", name)
+	}
+	p.tablePath = path
+	return nil
+}
+
+// descend returns the table at keys below the table at path, and its path,
+// creating the tables that don't exist yet. Headers lead through arrays of
+// tables into their last table; dotted keys may only lead through tables
+// that dotted keys created.
+func (p *tomlParser) descend(table map[string]interface{}, path string, keys []string, dotted bool, pos int) (map[string]interface{}, string, error) {
+	for i, key := range keys {
+		path = tomlPath(path, key)
+		name := strings.Join(keys[:i+1], ".")
+		if p.inline[path] {
+			p.pos = pos
+			return nil, "", fmt.Errorf("%s is defined inline, so it can't be extended", name)
+		}
+		switch next := table[key].(type) {
+		case nil:
+			created := make(map[string]interface{})
+			table[key] = created
+			table = created
+			p.dotted[path] = dotted
+		case map[string]interface{}:
+			if dotted && !p.dotted[path] {
+				p.pos = pos
+				return nil, "", fmt.Errorf("table %s is already defined, dotted keys can't extend it", name)
+			}
+			table = next
+		case []interface{}:
+			var last map[string]interface{}
+			ok := !dotted && len(next) > 0
+			if ok {
+				last, ok = next[len(next)-1].(map[string]interface{})
+			}
+			if !ok {
+				p.pos = pos
+				return nil, "", fmt.Errorf("%s is an array, not a table", name)
+			}
+			table = last
+			path = fmt.Sprintf("%s[%d]", path, len(next)-1)
+		default:
+			p.pos = pos
+			return nil, "", fmt.Errorf("%s is already defined as a value", name)
+		}
+	}
+	return table, path, nil
+}
+
+// keyValue parses key = value into table, whose path is path.
+func (p *tomlParser) keyValue(table map[string]interface{}, path string) error {
+	start := p.pos
+	keys, err := p.key()
+	if err != nil {
+		return err
+	}
+	p.skipSpace()
+	if p.eof() || p.src[p.pos] != '=' {
+		return errors.New("expected = after key")
+	}
+	p.pos++
+	p.skipSpace()
+
+	parent, parentPath, err := p.descend(table, path, keys[:len(keys)-1], true, start)
+	if err != nil {
+		return err
+	}
+	last := keys[len(keys)-1]
+	if _, ok := parent[last]; ok {
+		p.pos = start
+		return fmt.Errorf("key %s is defined twice", strings.Join(keys, "."))
+	}
+	value, err := p.value(tomlPath(parentPath, last))
+	if err != nil {
+		return err
+	}
+	parent[last] = value
+	return nil
+}
+
+var bareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+`)
+
+// key parses a possibly dotted key into its parts.
+func (p *tomlParser) key() ([]string, error) {
+	var keys []string
+	for {
+		p.skipSpace()
+		if p.eof() {
+			return nil, errors.New("expected a key")
+		}
+		switch p.src[p.pos] {
+		case '"', '\'':
+			key, err := p.str()
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+		default:
+			key := bareKey.FindString(p.src[p.pos:])
+			if key == "" {
+				return nil, fmt.Errorf("unexpected %q, expected a key", p.peekRune())
+			}
+			p.pos += len(key)
+			keys = append(keys, key)
+		}
+		p.skipSpace()
+		if p.eof() || p.src[p.pos] != '.' {
+			return keys, nil
+		}
+		p.pos++
+	}
+}
+
+var (
+	dateTimePattern = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}([Tt ]\d{2}:\d{2}(:\d{2}(\.\d+)?)?([Zz]|[+-]\d{2}:\d{2})?)?|\d{2}:\d{2}:\d{2}(\.\d+)?)`)
+	numberPattern   = regexp.MustCompile(`^[+-]?(0x[0-9A-Fa-f_]+|0o[0-7_]+|0b[01_]+|inf|nan|[0-9_]+(\.[0-9_]+)?([eE][+-]?[0-9_]+)?)`)
+)
+
+// value parses a value of any type, to be stored at path.
+func (p *tomlParser) value(path string) (interface{}, error) {
+	if p.eof() {
+		return nil, errors.New("expected a value")
+	}
+	rest := p.src[p.pos:]
+	switch {
+	case rest[0] == '"' || rest[0] == '\'':
+		return p.str()
+	case rest[0] == '[':
+		p.inline[path] = true
+		return p.array(path)
+	case rest[0] == '{':
+		p.inline[path] = true
+		return p.inlineTable(path)
+	case strings.HasPrefix(rest, "true") && !isBareKeyChar(rest, 4):
+		p.pos += 4
+		return true, nil
+	case strings.HasPrefix(rest, "false") && !isBareKeyChar(rest, 5):
+		p.pos += 5
+		return false, nil
+	}
+
+	if date := dateTimePattern.FindString(rest); date != "" {
+		p.pos += len(date)
+		return date, nil
+	}
+	number := numberPattern.FindString(rest)
+	if number == "" {
+		return nil, fmt.Errorf("unexpected %q, expected a value", p.peekRune())
+	}
+	value, err := parseNumber(number)
+	if err != nil {
+		return nil, err
+	}
+	p.pos += len(number)
+	return value, nil
+}
+
+func isBareKeyChar(s string, i int) bool {
+	return i < len(s) && bareKey.MatchString(s[i:i+1])
+}
+
+// parseNumber converts a TOML integer or float.
+func parseNumber(s string) (interface{}, error) {
+	if strings.Contains(s, "__") || strings.HasPrefix(strings.TrimLeft(s, "+-"), "_") || strings.HasSuffix(s, "_") {
+		return nil, fmt.Errorf("invalid number %s: underscores must be between digits", s)
+	}
+	clean := strings.ReplaceAll(s, "_", "")
+	unsigned := strings.TrimLeft(clean, "+-")
+	switch {
+	case unsigned == "inf":
+		if strings.HasPrefix(clean, "-") {
+			return math.Inf(-1), nil
+		}
+		return math.Inf(1), nil
+	case unsigned == "nan":
+		return math.NaN(), nil
+	case strings.HasPrefix(unsigned, "0x"), strings.HasPrefix(unsigned, "0o"), strings.HasPrefix(unsigned, "0b"):
+		if unsigned != clean {
+			return nil, fmt.Errorf("invalid number %s: only decimal numbers take a sign", s)
+		}
+		n, err := strconv.ParseInt(clean, 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s", s)
+		}
+		return int(n), nil
+	case strings.ContainsAny(clean, ".eE"):
+		f, err := strconv.ParseFloat(clean, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s", s)
+		}
+		return f, nil
+	}
+	if len(unsigned) > 1 && unsigned[0] == '0' {
+		return nil, fmt.Errorf("invalid number %s: leading zeros are not allowed", s)
+	}
+	n, err := strconv.ParseInt(clean, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number %s", s)
+	}
+	return int(n), nil
+}
+
+// array parses [value, ...], which may span lines and hold comments.
+func (p *tomlParser) array(path string) ([]interface{}, error) {
+	p.pos++ // [
+	list := []interface{}{}
+	for {
+		p.skipBlank()
+		if p.eof() {
+			return nil, errors.New("array is never closed with ]")
+		}
+		if p.src[p.pos] == ']' {
+			p.pos++
+			return list, nil
+		}
+		value, err := p.value(fmt.Sprintf("%s[%d]", path, len(list)))
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, value)
+		p.skipBlank()
+		if p.eof() {
+			return nil, errors.New("array is never closed with ]")
+		}
+		switch p.src[p.pos] {
+		case ',':
+			p.pos++
+		case ']':
+			p.pos++
+			return list, nil
+		default:
+			return nil, fmt.Errorf("unexpected %q in array, expected , or ]", p.peekRune())
+		}
+	}
+}
+
+// inlineTable parses {key = value, ...} on a single line.
+func (p *tomlParser) inlineTable(path string) (map[string]interface{}, error) {
+	p.pos++ // {
+	table := make(map[string]interface{})
+	p.skipSpace()
+	if !p.eof() && p.src[p.pos] == '}' {
+		p.pos++
+		return table, nil
+	}
+	for {
+		if err := p.keyValue(table, path); err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if p.eof() {
+			return nil, errors.New("inline table is never closed with }")
+		}
+		switch p.src[p.pos] {
+		case ',':
+			p.pos++
+			p.skipSpace()
+		case '}':
+			p.pos++
+			return table, nil
+		default:
+			return nil, fmt.Errorf("unexpected %q in inline table, expected , or }", p.peekRune())
+		}
+	}
+}
+
+// str parses a basic or literal string, on one line or, with triple quotes,
+// several.
+func (p *tomlParser) str() (string, error) {
+	quote := p.src[p.pos : p.pos+1]
+	multiline := strings.HasPrefix(p.src[p.pos:], strings.Repeat(quote, 3))
+	delimiter := quote
+	if multiline {
+		delimiter = strings.Repeat(quote, 3)
+	}
+	p.pos += len(delimiter)
+	if multiline {
+		// A newline right after the opening delimiter is trimmed
+		if strings.HasPrefix(p.src[p.pos:], "\r\n") {
+			p.pos += 2
+		} else if strings.HasPrefix(p.src[p.pos:], "\n") {
+			p.pos++
+		}
+	}
+
+	var b strings.Builder
+	for {
+		if p.eof() {
+			return "", errors.New("string is never closed")
+		}
+		rest := p.src[p.pos:]
+		switch {
+		case strings.HasPrefix(rest, delimiter):
+			// Up to two quotes may end a multiline string before its delimiter
+			extra := 0
+			for multiline && extra < 2 && strings.HasPrefix(rest[len(delimiter)+extra:], quote) {
+				extra++
+			}
+			b.WriteString(rest[:extra])
+			p.pos += len(delimiter) + extra
+			return b.String(), nil
+		case rest[0] == '\n' && !multiline:
+			return "", errors.New("string is never closed on its line")
+		case rest[0] == '\\' && quote == `"`:
+			if err := p.escape(&b, multiline); err != nil {
+				return "", err
+			}
+		default:
+			r, size := utf8.DecodeRuneInString(rest)
+			b.WriteRune(r)
+			p.pos += size
+		}
+	}
+}
+
+// escape parses an escape sequence in a basic string.
+func (p *tomlParser) escape(b *strings.Builder, multiline bool) error {
+	if p.pos+1 >= len(p.src) {
+		return errors.New("string is never closed")
+	}
+	c := p.src[p.pos+1]
+	simple := map[byte]string{'b': "\b", 't': "\t", 'n': "\n", 'f': "\f", 'r': "\r", '"': `"`, '\\': `\`}
+	if s, ok := simple[c]; ok {
+		b.WriteString(s)
+		p.pos += 2
+		return nil
+	}
+	switch c {
+	case 'u', 'U':
+		size := 4
+		if c == 'U' {
+			size = 8
+		}
+		if p.pos+2+size > len(p.src) {
+			return errors.New("incomplete unicode escape")
+		}
+		n, err := strconv.ParseUint(p.src[p.pos+2:p.pos+2+size], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(n)) {
+			return fmt.Errorf("invalid unicode escape \\%c%s", c, p.src[p.pos+2:p.pos+2+size])
+		}
+		b.WriteRune(rune(n))
+		p.pos += 2 + size
+		return nil
+	}
+	if multiline {
+		// A backslash at the end of a line trims the whitespace after it
+		end := p.pos + 1
+		for end < len(p.src) && (p.src[end] == ' ' || p.src[end] == '\t') {
+			end++
+		}
+		if end < len(p.src) && (p.src[end] == '\n' || p.src[end] == '\r') {
+			p.pos = end
+			for !p.eof() && strings.ContainsRune(" \t\r\n", rune(p.src[p.pos])) {
+				p.pos++
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid escape \\%c", c)
+}
+
+// endOfLine expects nothing but a comment after a key/value pair or header.
+func (p *tomlParser) endOfLine() error {
+	p.skipSpace()
+	p.skipComment()
+	if !p.eof() && p.src[p.pos] != '\n' && p.src[p.pos] != '\r' {
+		return fmt.Errorf("unexpected %q, expected the end of the line", p.peekRune())
+	}
+	p.skipNewline()
+	return nil
+}
+
+func (p *tomlParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *tomlParser) peekRune() rune {
+	r, _ := utf8.DecodeRuneInString(p.src[p.pos:])
+	return r
+}
+
+func (p *tomlParser) skipSpace() {
+	for !p.eof() && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *tomlParser) skipComment() {
+	if !p.eof() && p.src[p.pos] == '#' {
+		for !p.eof() && p.src[p.pos] != '\n' {
+			p.pos++
+		}
+	}
+}
+
+func (p *tomlParser) skipNewline() {
+	if strings.HasPrefix(p.src[p.pos:], "\r\n") {
+		p.pos += 2
+	} else if !p.eof() && (p.src[p.pos] == '\n' || p.src[p.pos] == '\r') {
+		p.pos++
+	}
+}
+
+// skipBlank skips whitespace, newlines and comments, as allowed in arrays.
+func (p *tomlParser) skipBlank() {
+	for {
+		start := p.pos
+		p.skipSpace()
+		p.skipComment()
+		p.skipNewline()
+		if p.pos == start {
+			return
+		}
+	}
+}
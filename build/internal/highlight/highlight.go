@@ -0,0 +1,293 @@
+// Package highlight colours fenced code blocks at build time, so pages need
+// neither client-side scripts nor a third-party highlighter.
+package highlight
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Style is a colour scheme: CSS declarations for the block, highlighted
+// lines, line numbers and every token class.
+type Style struct {
+	Background  string
+	Highlight   string
+	LineNumbers string
+	Tokens      map[string]string
+}
+
+// Styles are the colour schemes that can be selected in config.json.
+var Styles = map[string]Style{
+	"github": {
+		Background:  "color: #24292e; background-color: #f6f8fa",
+		Highlight:   "background-color: #fff5b1",
+		LineNumbers: "color: #959da5",
+		Tokens: map[string]string{
+			Keyword:  "color: #d73a49",
+			Type:     "color: #d73a49",
+			Builtin:  "color: #005cc5",
+			Function: "color: #6f42c1",
+			String:   "color: #032f62",
+			Number:   "color: #005cc5",
+			Comment:  "color: #6a737d; font-style: italic",
+		},
+	},
+	"monokai": {
+		Background:  "color: #f8f8f2; background-color: #272822",
+		Highlight:   "background-color: #49483e",
+		LineNumbers: "color: #75715e",
+		Tokens: map[string]string{
+			Keyword:  "color: #f92672",
+			Type:     "color: #66d9ef",
+			Builtin:  "color: #66d9ef",
+			Function: "color: #a6e22e",
+			String:   "color: #e6db74",
+			Number:   "color: #ae81ff",
+			Comment:  "color: #75715e",
+		},
+	},
+}
+
+// DefaultStyle is used when the config doesn't name one.
+const DefaultStyle = "github"
+
+// Highlighter renders code blocks in one style, either with classes styled by
+// the stylesheet from CSS or with inline styles.
+type Highlighter struct {
+	style       Style
+	inline      bool
+	lineNumbers bool
+}
+
+// New returns a highlighter for a style. Output is "classes" (the default) or
+// "inline"; lineNumbers numbers every block unless its fence says otherwise.
+func New(style, output string, lineNumbers bool) (*Highlighter, error) {
+	if style == "" {
+		style = DefaultStyle
+	}
+	s, ok := Styles[style]
+	if !ok {
+		return nil, fmt.Errorf("unknown highlight style %q", style)
+	}
+	switch output {
+	case "", "classes", "inline":
+	default:
+		return nil, fmt.Errorf("unknown highlight output %q, expected classes or inline", output)
+	}
+	return &Highlighter{style: s, inline: output == "inline", lineNumbers: lineNumbers}, nil
+}
+
+// Options are the attributes of a fence, as in ```go {linenos=true hl_lines="2 4-5"}.
+type Options struct {
+	Language    string
+	LineNumbers bool
+	LineStart   int
+	Lines       map[int]bool // Highlighted lines, numbered from LineStart
+}
+
+// ParseInfo reads the info string of a fence around code: the language
+// followed by optional attributes, with or without braces around them.
+// Attributes it doesn't know are ignored.
+func (h *Highlighter) ParseInfo(info, code string) (Options, error) {
+	opts := Options{LineNumbers: h.lineNumbers, LineStart: 1}
+	info = strings.TrimSpace(info)
+	if info != "" && !strings.ContainsAny(strings.Fields(info)[0], "={") {
+		opts.Language = strings.Fields(info)[0]
+		info = info[len(opts.Language):]
+	}
+	info = strings.Trim(strings.TrimSpace(info), "{}")
+
+	attrs, err := attributes(info)
+	if err != nil {
+		return opts, err
+	}
+	for _, key := range []string{"linenos", "linenostart", "hl_lines"} {
+		value, ok := attrs[key]
+		if !ok {
+			continue
+		}
+		switch key {
+		case "linenos":
+			switch value {
+			case "true", "table", "inline":
+				opts.LineNumbers = true
+			case "false":
+				opts.LineNumbers = false
+			default:
+				return opts, fmt.Errorf("linenos: expected true or false, got %q", value)
+			}
+		case "linenostart":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return opts, fmt.Errorf("linenostart: %w", err)
+			}
+			opts.LineStart = n
+		case "hl_lines":
+			// Highlighted lines are numbered like the lines of the block, so
+			// they are read once its first line number is known
+			count := strings.Count(strings.TrimSuffix(code, "\n"), "\n") + 1
+			lines, err := lineRanges(value, opts.LineStart, opts.LineStart+count-1)
+			if err != nil {
+				return opts, fmt.Errorf("hl_lines: %w", err)
+			}
+			opts.Lines = lines
+		}
+	}
+	return opts, nil
+}
+
+// attributes splits key=value pairs separated by spaces or commas. Values may
+// be quoted.
+func attributes(s string) (map[string]string, error) {
+	attrs := make(map[string]string)
+	for s = strings.TrimLeft(s, " ,"); s != ""; s = strings.TrimLeft(s, " ,") {
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 {
+			return nil, fmt.Errorf("expected key=value in %q", s)
+		}
+		key := strings.TrimSpace(s[:eq])
+		s = strings.TrimLeft(s[eq+1:], " ")
+
+		var value string
+		if s != "" && (s[0] == '"' || s[0] == '\'') {
+			end := strings.IndexByte(s[1:], s[0])
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated value for %s", key)
+			}
+			value, s = s[1:end+1], s[end+2:]
+		} else {
+			end := strings.IndexAny(s, " ,")
+			if end < 0 {
+				end = len(s)
+			}
+			value, s = s[:end], s[end:]
+		}
+		attrs[key] = value
+	}
+	return attrs, nil
+}
+
+// lineRanges parses line numbers and ranges such as "2 4-5", keeping the lines
+// from first to last.
+func lineRanges(s string, first, last int) (map[int]bool, error) {
+	lines := make(map[int]bool)
+	for _, field := range strings.FieldsFunc(s, func(r rune) bool { return r == ' ' || r == ',' || r == '[' || r == ']' }) {
+		from, to, isRange := strings.Cut(field, "-")
+		start, err := strconv.Atoi(from)
+		if err != nil {
+			return nil, err
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(to); err != nil {
+				return nil, err
+			}
+		}
+		for n := max(start, first); n <= min(end, last); n++ {
+			lines[n] = true
+		}
+	}
+	return lines, nil
+}
+
+// Block renders code as a highlighted <pre> block.
+func (h *Highlighter) Block(code string, opts Options) string {
+	var b strings.Builder
+	b.WriteString(`<div class="highlight"><pre`)
+	if h.inline {
+		fmt.Fprintf(&b, ` style="%s"`, h.style.Background)
+	}
+	b.WriteString(`><code`)
+	if opts.Language != "" {
+		fmt.Fprintf(&b, ` class="language-%s" data-lang="%s"`, html.EscapeString(opts.Language), html.EscapeString(opts.Language))
+	}
+	b.WriteString(">")
+
+	code = strings.TrimSuffix(code, "\n")
+	for i, line := range splitLines(tokenize(lookup(opts.Language), code)) {
+		n := opts.LineStart + i
+		// Lines are flex boxes so a highlighted line's background spans the
+		// block and the newline inside it doesn't add a blank line. The code
+		// of a line is a single flex item, in its own span, so the spaces
+		// between its tokens aren't dropped as items of their own
+		b.WriteString(`<span class="line`)
+		switch {
+		case opts.Lines[n] && h.inline:
+			fmt.Fprintf(&b, ` hl" style="display: flex; %s">`, h.style.Highlight)
+		case opts.Lines[n]:
+			b.WriteString(` hl">`)
+		case h.inline:
+			b.WriteString(`" style="display: flex">`)
+		default:
+			b.WriteString(`">`)
+		}
+		if opts.LineNumbers {
+			b.WriteString(`<span class="ln"`)
+			if h.inline {
+				fmt.Fprintf(&b, ` style="%s; user-select: none; margin-right: 1em"`, h.style.LineNumbers)
+			}
+			fmt.Fprintf(&b, ">%*d</span>", len(strconv.Itoa(opts.LineStart+strings.Count(code, "\n"))), n)
+		}
+		b.WriteString(`<span class="cl">`)
+		for _, token := range line {
+			text := html.EscapeString(token.Text)
+			switch {
+			case token.Class == Plain:
+				b.WriteString(text)
+			case h.inline:
+				fmt.Fprintf(&b, `<span style="%s">%s</span>`, h.style.Tokens[token.Class], text)
+			default:
+				fmt.Fprintf(&b, `<span class="%s">%s</span>`, token.Class, text)
+			}
+		}
+		b.WriteString("\n</span></span>")
+	}
+	b.WriteString("</code></pre></div>\n")
+	return b.String()
+}
+
+// splitLines breaks tokens at newlines, so every line can be wrapped and
+// numbered on its own.
+func splitLines(tokens []Token) [][]Token {
+	lines := [][]Token{nil}
+	for _, token := range tokens {
+		parts := strings.Split(token.Text, "\n")
+		for i, part := range parts {
+			if i > 0 {
+				lines = append(lines, nil)
+			}
+			if part != "" {
+				lines[len(lines)-1] = append(lines[len(lines)-1], Token{token.Class, part})
+			}
+		}
+	}
+	return lines
+}
+
+// CSS returns the stylesheet for class output, scoped to .highlight blocks.
+func (h *Highlighter) CSS() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, ".highlight pre { %s; }\n", h.style.Background)
+	b.WriteString(".highlight .line { display: flex; }\n")
+	fmt.Fprintf(&b, ".highlight .line.hl { %s; }\n", h.style.Highlight)
+	fmt.Fprintf(&b, ".highlight .ln { %s; user-select: none; margin-right: 1em; }\n", h.style.LineNumbers)
+
+	classes := make([]string, 0, len(h.style.Tokens))
+	for class := range h.style.Tokens {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	for _, class := range classes {
+		fmt.Fprintf(&b, ".highlight .%s { %s; }\n", class, h.style.Tokens[class])
+	}
+	return b.String()
+}
+
+// Inline reports whether styles are written into the markup, in which case no
+// stylesheet is needed.
+func (h *Highlighter) Inline() bool {
+	return h.inline
+}
@@ -0,0 +1,136 @@
+package highlight
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		lang string
+		src  string
+		want []Token
+	}{
+		{"go", "func main() {}", []Token{{Keyword, "func"}, {Plain, " "}, {Function, "main"}, {Plain, "() {}"}}},
+		{"go", "x := len(s) // count", []Token{{Plain, "x := "}, {Builtin, "len"}, {Plain, "(s) "}, {Comment, "// count"}}},
+		{"go", "var n int = 0x1F", []Token{{Keyword, "var"}, {Plain, " n "}, {Type, "int"}, {Plain, " = "}, {Number, "0x1F"}}},
+		{"go", "s := `a\\` + \"b\\\"c\"", []Token{{Plain, "s := "}, {String, "`a\\`"}, {Plain, " + "}, {String, "\"b\\\"c\""}}},
+		{"go", "/* a\nb */ x", []Token{{Comment, "/* a\nb */"}, {Plain, " x"}}},
+		{"py", "def f(): return None # done", []Token{
+			{Keyword, "def"}, {Plain, " "}, {Function, "f"}, {Plain, "(): "}, {Keyword, "return"}, {Plain, " "},
+			{Builtin, "None"}, {Plain, " "}, {Comment, "# done"},
+		}},
+		{"python", `s = """a "quoted" b"""`, []Token{{Plain, "s = "}, {String, `"""a "quoted" b"""`}}},
+		{"sh", "echo $HOME 'it''s'", []Token{{Builtin, "echo"}, {Plain, " "}, {Builtin, "$HOME"}, {Plain, " "}, {String, "'it''s'"}}},
+		{"ts", "let n: number", []Token{{Keyword, "let"}, {Plain, " n: "}, {Type, "number"}}},
+		{"js", "let type = 1", []Token{{Keyword, "let"}, {Plain, " type = "}, {Number, "1"}}},
+		{"json", `{"a": true}`, []Token{{Plain, "{"}, {String, `"a"`}, {Plain, ": "}, {Builtin, "true"}, {Plain, "}"}}},
+		{"unknown", "func main", []Token{{Plain, "func main"}}},
+		{"", "func main", []Token{{Plain, "func main"}}},
+	}
+	for _, tt := range tests {
+		got := tokenize(lookup(tt.lang), tt.src)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("tokenize(%s, %q) = %q, want %q", tt.lang, tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestParseInfo(t *testing.T) {
+	code := "a\nb\nc\nd\ne\n"
+	tests := []struct {
+		info string
+		want Options
+	}{
+		{"", Options{LineStart: 1}},
+		{"go", Options{Language: "go", LineStart: 1}},
+		{"go {linenos=true}", Options{Language: "go", LineNumbers: true, LineStart: 1}},
+		{"go {linenos=table, linenostart=10}", Options{Language: "go", LineNumbers: true, LineStart: 10}},
+		{`go {hl_lines="2 4-5"}`, Options{Language: "go", LineStart: 1, Lines: map[int]bool{2: true, 4: true, 5: true}}},
+		{`{hl_lines="[1,3]"}`, Options{LineStart: 1, Lines: map[int]bool{1: true, 3: true}}},
+		// Highlighted lines are numbered from linenostart, whatever the order
+		{`go {hl_lines="10-11" linenostart=10}`, Options{Language: "go", LineStart: 10, Lines: map[int]bool{10: true, 11: true}}},
+		// Ranges are cut to the lines of the block
+		{`go {hl_lines="4-1000000000"}`, Options{Language: "go", LineStart: 1, Lines: map[int]bool{4: true, 5: true}}},
+		{`go {hl_lines="0 9"}`, Options{Language: "go", LineStart: 1, Lines: map[int]bool{}}},
+		{"go {title=main.go}", Options{Language: "go", LineStart: 1}},
+	}
+	h, err := New("", "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		got, err := h.ParseInfo(tt.info, code)
+		if err != nil {
+			t.Errorf("ParseInfo(%q): %v", tt.info, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseInfo(%q) = %+v, want %+v", tt.info, got, tt.want)
+		}
+	}
+}
+
+func TestParseInfoErrors(t *testing.T) {
+	h, err := New("", "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, info := range []string{
+		"go {linenos=maybe}",
+		"go {linenostart=one}",
+		`go {hl_lines="2-x"}`,
+		`go {hl_lines="two"}`,
+		`go {hl_lines="2}`,
+		"go {linenos}",
+	} {
+		if _, err := h.ParseInfo(info, "a\nb\n"); err == nil {
+			t.Errorf("ParseInfo(%q) accepted invalid attributes", info)
+		}
+	}
+	if opts, _ := h.ParseInfo("go", ""); !opts.LineNumbers {
+		t.Error("ParseInfo doesn't number lines by default when the highlighter does")
+	}
+}
+
+func TestBlock(t *testing.T) {
+	h, err := New("", "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := h.Block("func main() {\n}\n", Options{Language: "go", LineStart: 1, Lines: map[int]bool{2: true}})
+	want := `<div class="highlight"><pre><code class="language-go" data-lang="go">` +
+		`<span class="line"><span class="cl"><span class="k">func</span> <span class="nf">main</span>() {` + "\n</span></span>" +
+		`<span class="line hl"><span class="cl">}` + "\n</span></span>" +
+		"</code></pre></div>\n"
+	if got != want {
+		t.Errorf("Block =\n%s\nwant\n%s", got, want)
+	}
+
+	numbered := h.Block(strings.Repeat("x\n", 10), Options{LineNumbers: true, LineStart: 1})
+	if !strings.Contains(numbered, `<span class="ln"> 1</span><span class="cl">x`) || !strings.Contains(numbered, `<span class="ln">10</span>`) {
+		t.Errorf("line numbers aren't padded to the same width:\n%s", numbered)
+	}
+
+	escaped := h.Block("<b>&</b>", Options{LineStart: 1})
+	if !strings.Contains(escaped, "&lt;b&gt;&amp;&lt;/b&gt;") {
+		t.Errorf("code isn't escaped:\n%s", escaped)
+	}
+}
+
+func TestBlockInline(t *testing.T) {
+	h, err := New("monokai", "inline", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := h.Block("func", Options{Language: "go", LineStart: 1, Lines: map[int]bool{1: true}})
+	want := `<span class="line hl" style="display: flex; background-color: #49483e"><span class="cl">` +
+		`<span style="color: #f92672">func</span>` + "\n</span></span>"
+	if !strings.Contains(got, want) {
+		t.Errorf("Block =\n%s\nwant it to contain\n%s", got, want)
+	}
+	if !h.Inline() {
+		t.Error("Inline = false for inline output")
+	}
+}
@@ -0,0 +1,286 @@
+package highlight
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Token classes, named like the CSS classes they are rendered with.
+const (
+	Plain    = ""
+	Keyword  = "k"
+	Type     = "kt"
+	Builtin  = "nb"
+	Function = "nf"
+	String   = "s"
+	Number   = "m"
+	Comment  = "c"
+)
+
+// Token is a run of source text of one class.
+type Token struct {
+	Class string
+	Text  string
+}
+
+// language describes just enough of a language's lexical structure to colour
+// it: its words and how its comments and strings are delimited.
+type language struct {
+	keywords      []string
+	types         []string
+	builtins      []string
+	lineComments  []string
+	blockComments [][2]string
+	strings       []string // Delimiters, longest first; the same delimiter closes the string
+	rawStrings    []string // Delimiters of strings without escapes
+	variables     bool     // $name is a builtin, as in shell scripts
+
+	words map[string]string // Class of every keyword, type and builtin
+}
+
+var languages = map[string]*language{
+	"go": {
+		keywords: []string{"break", "case", "chan", "const", "continue", "default", "defer", "else", "fallthrough", "for",
+			"func", "go", "goto", "if", "import", "interface", "map", "package", "range", "return", "select", "struct",
+			"switch", "type", "var"},
+		types: []string{"any", "bool", "byte", "comparable", "complex64", "complex128", "error", "float32", "float64",
+			"int", "int8", "int16", "int32", "int64", "rune", "string", "uint", "uint8", "uint16", "uint32", "uint64", "uintptr"},
+		builtins: []string{"append", "cap", "clear", "close", "complex", "copy", "delete", "false", "imag", "iota", "len",
+			"make", "max", "min", "new", "nil", "panic", "print", "println", "real", "recover", "true"},
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		strings:       []string{`"`, `'`},
+		rawStrings:    []string{"`"},
+	},
+	"python": {
+		keywords: []string{"and", "as", "assert", "async", "await", "break", "class", "continue", "def", "del", "elif",
+			"else", "except", "finally", "for", "from", "global", "if", "import", "in", "is", "lambda", "nonlocal", "not",
+			"or", "pass", "raise", "return", "try", "while", "with", "yield"},
+		types: []string{"bool", "bytes", "dict", "float", "frozenset", "int", "list", "object", "set", "str", "tuple"},
+		builtins: []string{"False", "None", "True", "abs", "all", "any", "enumerate", "filter", "isinstance", "len",
+			"map", "max", "min", "open", "print", "range", "reversed", "self", "sorted", "sum", "super", "type", "zip"},
+		lineComments: []string{"#"},
+		strings:      []string{`"""`, `'''`, `"`, `'`},
+	},
+	"javascript": {
+		keywords: []string{"async", "await", "break", "case", "catch", "class", "const", "continue", "debugger",
+			"default", "delete", "do", "else", "export", "extends", "finally", "for", "from", "function", "if", "import",
+			"in", "instanceof", "let", "new", "of", "return", "static", "super", "switch", "this", "throw", "try",
+			"typeof", "var", "void", "while", "with", "yield"},
+		types: []string{"Array", "Boolean", "Date", "Error", "Map", "Number", "Object", "Promise", "RegExp", "Set",
+			"String", "Symbol"},
+		builtins:      []string{"console", "document", "false", "globalThis", "null", "true", "undefined", "window"},
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		strings:       []string{`"`, `'`, "`"},
+	},
+	"bash": {
+		keywords: []string{"case", "do", "done", "elif", "else", "esac", "export", "fi", "for", "function", "if", "in",
+			"local", "return", "then", "until", "while"},
+		builtins:     []string{"cd", "echo", "exit", "printf", "read", "set", "shift", "source", "test", "unset"},
+		lineComments: []string{"#"},
+		strings:      []string{`"`},
+		rawStrings:   []string{`'`},
+		variables:    true,
+	},
+	"json": {
+		builtins: []string{"false", "null", "true"},
+		strings:  []string{`"`},
+	},
+}
+
+// aliases maps the names used in fence info strings to languages.
+var aliases = map[string]string{
+	"golang": "go",
+	"py":     "python",
+	"js":     "javascript",
+	"jsx":    "javascript",
+	"ts":     "typescript",
+	"tsx":    "typescript",
+	"sh":     "bash",
+	"shell":  "bash",
+	"zsh":    "bash",
+}
+
+func init() {
+	for _, lang := range languages {
+		lang.words = make(map[string]string)
+		for class, words := range map[string][]string{Keyword: lang.keywords, Type: lang.types, Builtin: lang.builtins} {
+			for _, word := range words {
+				lang.words[word] = class
+			}
+		}
+	}
+	// TypeScript shares JavaScript's lexer plus its own keywords and types
+	ts := *languages["javascript"]
+	ts.words = make(map[string]string)
+	for word, class := range languages["javascript"].words {
+		ts.words[word] = class
+	}
+	for _, word := range []string{"abstract", "declare", "enum", "implements", "interface", "keyof", "namespace",
+		"private", "protected", "public", "readonly", "type"} {
+		ts.words[word] = Keyword
+	}
+	for _, word := range []string{"any", "boolean", "never", "number", "string", "unknown", "void"} {
+		ts.words[word] = Type
+	}
+	languages["typescript"] = &ts
+}
+
+// lookup returns the language named in a fence info string, or nil.
+func lookup(name string) *language {
+	name = strings.ToLower(name)
+	if alias, ok := aliases[name]; ok {
+		name = alias
+	}
+	return languages[name]
+}
+
+// tokenize splits source into classed tokens. Unknown languages come back as
+// a single plain token.
+func tokenize(lang *language, src string) []Token {
+	if lang == nil {
+		return []Token{{Plain, src}}
+	}
+
+	var tokens []Token
+	emit := func(class, text string) {
+		if text == "" {
+			return
+		}
+		if n := len(tokens); n > 0 && tokens[n-1].Class == class {
+			tokens[n-1].Text += text
+			return
+		}
+		tokens = append(tokens, Token{class, text})
+	}
+
+	for i := 0; i < len(src); {
+		rest := src[i:]
+
+		if end := lang.comment(rest); end > 0 {
+			emit(Comment, rest[:end])
+			i += end
+			continue
+		}
+		if end := lang.str(rest); end > 0 {
+			emit(String, rest[:end])
+			i += end
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(rest)
+		switch {
+		case lang.variables && r == '$' && len(rest) > 1 && isWordStart(rune(rest[1])):
+			end := 1 + wordEnd(rest[1:])
+			emit(Builtin, rest[:end])
+			i += end
+		case unicode.IsDigit(r):
+			end := numberEnd(rest)
+			emit(Number, rest[:end])
+			i += end
+		case isWordStart(r):
+			end := wordEnd(rest)
+			word := rest[:end]
+			class, ok := lang.words[word]
+			if !ok && strings.HasPrefix(strings.TrimLeft(rest[end:], " "), "(") {
+				class = Function
+			}
+			emit(class, word)
+			i += end
+		default:
+			emit(Plain, rest[:size])
+			i += size
+		}
+	}
+	return tokens
+}
+
+// comment returns the length of the comment at the start of s, or 0.
+func (lang *language) comment(s string) int {
+	for _, marker := range lang.lineComments {
+		if strings.HasPrefix(s, marker) {
+			if end := strings.IndexByte(s, '\n'); end >= 0 {
+				return end
+			}
+			return len(s)
+		}
+	}
+	for _, markers := range lang.blockComments {
+		if strings.HasPrefix(s, markers[0]) {
+			if end := strings.Index(s[len(markers[0]):], markers[1]); end >= 0 {
+				return len(markers[0]) + end + len(markers[1])
+			}
+			return len(s)
+		}
+	}
+	return 0
+}
+
+// str returns the length of the string literal at the start of s, or 0.
+// Unterminated strings run to the end of the line, or of the source for
+// delimiters that may span lines.
+func (lang *language) str(s string) int {
+	for _, raw := range []bool{false, true} {
+		delimiters := lang.strings
+		if raw {
+			delimiters = lang.rawStrings
+		}
+		for _, delim := range delimiters {
+			if !strings.HasPrefix(s, delim) {
+				continue
+			}
+			multiline := raw || len(delim) == 3 || delim == "`"
+			for i := len(delim); i < len(s); i++ {
+				switch {
+				case !raw && s[i] == '\\':
+					i++
+				case strings.HasPrefix(s[i:], delim):
+					return i + len(delim)
+				case s[i] == '\n' && !multiline:
+					return i
+				}
+			}
+			return len(s)
+		}
+	}
+	return 0
+}
+
+func isWordStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+// wordEnd returns the length of the identifier at the start of s.
+func wordEnd(s string) int {
+	for i, r := range s {
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return i
+		}
+	}
+	return len(s)
+}
+
+// numberEnd returns the length of the number at the start of s, covering
+// hexadecimal, fractions, exponents and digit separators.
+func numberEnd(s string) int {
+	hex := len(s) > 1 && s[0] == '0' && (s[1] == 'x' || s[1] == 'X')
+	i := 1
+	if hex {
+		i = 2
+	}
+	for ; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= '0' && c <= '9', c == '_':
+		case hex && (c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'):
+		case c == '.' && i+1 < len(s) && s[i+1] >= '0' && s[i+1] <= '9':
+		case !hex && (c == 'e' || c == 'E'):
+		case (c == '+' || c == '-') && (s[i-1] == 'e' || s[i-1] == 'E'):
+		default:
+			return i
+		}
+	}
+	return len(s)
+}
@@ -0,0 +1,334 @@
+package markdown
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"ts-www/build/internal/config"
+	"ts-www/build/internal/highlight"
+
+	"github.com/russross/blackfriday/v2"
+)
+
+// Extensions that can be toggled in config.json. Without a list the renderer
+// keeps the behaviour of blackfriday's defaults.
+var extensions = map[string]struct {
+	parser blackfriday.Extensions
+	html   blackfriday.HTMLFlags
+}{
+	"tables":          {parser: blackfriday.Tables},
+	"fencedCode":      {parser: blackfriday.FencedCode},
+	"autolink":        {parser: blackfriday.Autolink},
+	"strikethrough":   {parser: blackfriday.Strikethrough},
+	"definitionLists": {parser: blackfriday.DefinitionLists},
+	"footnotes":       {parser: blackfriday.Footnotes, html: blackfriday.FootnoteReturnLinks},
+	"headingIDs":      {parser: blackfriday.HeadingIDs | blackfriday.AutoHeadingIDs},
+	"hardLineBreaks":  {parser: blackfriday.HardLineBreak},
+	"typographer":     {html: blackfriday.Smartypants | blackfriday.SmartypantsFractions | blackfriday.SmartypantsDashes | blackfriday.SmartypantsLatexDashes},
+	"xhtml":           {html: blackfriday.UseXHTML},
+	"taskLists":       {},
+}
+
+// DefaultExtensions matches blackfriday's common extensions and HTML flags.
+var DefaultExtensions = []string{"tables", "fencedCode", "autolink", "strikethrough", "definitionLists", "typographer", "xhtml"}
+
+// Always on: they only make the parser stricter or saner.
+const baseExtensions = blackfriday.NoIntraEmphasis | blackfriday.SpaceHeadings | blackfriday.BackslashLineBreak
+
+// Renderer converts markdown to HTML with a fixed set of extensions. It is
+// safe for concurrent use.
+type Renderer struct {
+	parser    blackfriday.Extensions
+	html      blackfriday.HTMLFlags
+	taskLists bool
+	tocStart  int
+	tocEnd    int
+	highlight *highlight.Highlighter // Nil leaves code blocks as plain <pre><code>
+}
+
+// Result is a rendered markdown document.
+type Result struct {
+	HTML     template.HTML
+	TOC      template.HTML // Nested list linking to the headings, empty without heading IDs
+	Headings []Heading
+	Warnings []*Warning // Problems that didn't stop the document rendering
+}
+
+// Warning is a problem with part of a document, such as a code fence whose
+// attributes don't parse.
+type Warning struct {
+	Line int // Line of the document the problem is on, counted from 1
+	Err  error
+}
+
+func (w *Warning) Error() string {
+	return fmt.Sprintf("line %d: %v", w.Line, w.Err)
+}
+
+func (w *Warning) Unwrap() error {
+	return w.Err
+}
+
+// Heading is a heading of a rendered document.
+type Heading struct {
+	Level int
+	ID    string
+	Text  string
+}
+
+// New returns a renderer for the configured extensions. Unknown extensions
+// are reported in the error but the returned renderer is always usable, with
+// the extensions it does know.
+func New(cfg config.MarkdownConfig) (*Renderer, error) {
+	r := &Renderer{parser: baseExtensions, tocStart: cfg.TOC.StartLevel, tocEnd: cfg.TOC.EndLevel}
+	if r.tocStart == 0 {
+		r.tocStart = 2
+	}
+	if r.tocEnd == 0 {
+		r.tocEnd = 4
+	}
+
+	names := cfg.Extensions
+	if names == nil {
+		names = DefaultExtensions
+	}
+	var unknown []string
+	for _, name := range names {
+		ext, ok := extensions[name]
+		if !ok {
+			unknown = append(unknown, name)
+			continue
+		}
+		r.parser |= ext.parser
+		r.html |= ext.html
+		if name == "taskLists" {
+			r.taskLists = true
+		}
+	}
+	if len(unknown) > 0 {
+		return r, fmt.Errorf("unknown markdown extensions: %s", strings.Join(unknown, ", "))
+	}
+
+	if hc := cfg.Highlight; hc.Enabled {
+		h, err := highlight.New(hc.Style, hc.Output, hc.LineNumbers)
+		if err != nil {
+			return r, fmt.Errorf("markdown highlight: %w", err)
+		}
+		r.highlight = h
+	}
+	return r, nil
+}
+
+// StyleSheet is the path under assets of the stylesheet for highlighted code.
+const StyleSheet = "css/syntax.css"
+
+// HighlightCSS returns the stylesheet for highlighted code, empty unless code
+// is highlighted with classes.
+func (r *Renderer) HighlightCSS() string {
+	if r.highlight == nil || r.highlight.Inline() {
+		return ""
+	}
+	return r.highlight.CSS()
+}
+
+// WriteStyleSheet writes the stylesheet for highlighted code into the assets
+// directory when there is one.
+func WriteStyleSheet(cfg config.MarkdownConfig, assetsDir string) error {
+	r, _ := New(cfg) // Config errors are reported when the site loads
+	css := r.HighlightCSS()
+	if css == "" {
+		return nil
+	}
+	path := filepath.Join(assetsDir, filepath.FromSlash(StyleSheet))
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(css), 0644)
+}
+
+// HTML renders markdown for templates, accepting anything that prints as
+// markdown like the markDown template function always has.
+func (r *Renderer) HTML(args ...interface{}) template.HTML {
+	return r.Render([]byte(fmt.Sprintf("%s", args...))).HTML
+}
+
+// Headings lists the headings of a markdown document without rendering it.
+func (r *Renderer) Headings(src []byte) []Heading {
+	_, headings := r.parse(src)
+	return headings
+}
+
+// parse parses a markdown document and lists its headings, giving each the ID
+// it ends up with in the HTML. Task list items are turned into tasks.
+func (r *Renderer) parse(src []byte) (*blackfriday.Node, []Heading) {
+	parser := blackfriday.New(blackfriday.WithExtensions(r.parser))
+	ast := parser.Parse(src)
+
+	var headings []Heading
+	ids := make(map[string]int)
+	ast.Walk(func(node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+		if !entering {
+			return blackfriday.GoToNext
+		}
+		switch node.Type {
+		case blackfriday.Heading:
+			if node.HeadingID != "" && !node.IsTitleblock {
+				node.HeadingID = uniqueID(ids, node.HeadingID)
+				headings = append(headings, Heading{Level: node.Level, ID: node.HeadingID, Text: plainText(node)})
+			}
+		case blackfriday.Item:
+			if r.taskLists {
+				taskItem(node)
+			}
+		}
+		return blackfriday.GoToNext
+	})
+	return ast, headings
+}
+
+// Render converts a markdown document to HTML and collects its headings.
+func (r *Renderer) Render(src []byte) Result {
+	ast, headings := r.parse(src)
+	result := Result{Headings: headings}
+
+	// A renderer remembers the heading IDs it has seen, so each document needs
+	// its own
+	renderer := blackfriday.NewHTMLRenderer(blackfriday.HTMLRendererParameters{Flags: r.html})
+	var buf bytes.Buffer
+	renderer.RenderHeader(&buf, ast)
+	offset := 0 // Where to look for the next fence in src
+	ast.Walk(func(node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+		if node.Type == blackfriday.CodeBlock && r.highlight != nil {
+			// Attributes that don't parse are reported but don't fail the
+			// page; the block is still coloured with what was understood
+			opts, err := r.highlight.ParseInfo(string(node.Info), string(node.Literal))
+			if err != nil {
+				var line int
+				line, offset = fenceLine(src, offset, node.Info)
+				result.Warnings = append(result.Warnings, &Warning{Line: line, Err: fmt.Errorf("code block: %w", err)})
+			}
+			buf.WriteString(r.highlight.Block(string(node.Literal), opts))
+			return blackfriday.GoToNext
+		}
+		return renderer.RenderNode(&buf, node, entering)
+	})
+	renderer.RenderFooter(&buf, ast)
+
+	result.HTML = template.HTML(buf.String())
+	result.TOC = r.toc(result.Headings)
+	return result
+}
+
+// fenceLine returns the line of the first fence from offset with the info
+// string, and the offset after it. Blackfriday doesn't keep the positions of
+// nodes, so the fence is found again in the source.
+func fenceLine(src []byte, offset int, info []byte) (int, int) {
+	i := bytes.Index(src[offset:], info)
+	if i < 0 {
+		return 0, offset
+	}
+	end := offset + i + len(info)
+	return bytes.Count(src[:end], []byte("\n")) + 1, end
+}
+
+// uniqueID gives repeated heading IDs a numeric suffix the same way
+// blackfriday's renderer does, so the IDs listed in the table of contents are
+// the ones that end up in the HTML.
+func uniqueID(ids map[string]int, id string) string {
+	for count, found := ids[id]; found; count, found = ids[id] {
+		tmp := fmt.Sprintf("%s-%d", id, count+1)
+		if _, tmpFound := ids[tmp]; !tmpFound {
+			ids[id] = count + 1
+			id = tmp
+		} else {
+			id = id + "-1"
+		}
+	}
+	if _, found := ids[id]; !found {
+		ids[id] = 0
+	}
+	return id
+}
+
+// plainText returns the text content of a node.
+func plainText(node *blackfriday.Node) string {
+	var b strings.Builder
+	node.Walk(func(n *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+		if entering && (n.Type == blackfriday.Text || n.Type == blackfriday.Code) {
+			b.Write(n.Literal)
+		}
+		return blackfriday.GoToNext
+	})
+	return b.String()
+}
+
+// taskItem turns a list item starting with "[ ]" or "[x]" into a task with a
+// disabled checkbox.
+func taskItem(item *blackfriday.Node) {
+	paragraph := item.FirstChild
+	if paragraph == nil || paragraph.Type != blackfriday.Paragraph {
+		return
+	}
+	text := paragraph.FirstChild
+	if text == nil || text.Type != blackfriday.Text || len(text.Literal) < 4 {
+		return
+	}
+
+	var checkbox string
+	switch string(text.Literal[:4]) {
+	case "[ ] ":
+		checkbox = `<input type="checkbox" disabled> `
+	case "[x] ", "[X] ":
+		checkbox = `<input type="checkbox" checked disabled> `
+	default:
+		return
+	}
+	text.Literal = text.Literal[4:]
+	span := blackfriday.NewNode(blackfriday.HTMLSpan)
+	span.Literal = []byte(checkbox)
+	text.InsertBefore(span)
+}
+
+// toc renders the headings within the configured levels as nested lists.
+func (r *Renderer) toc(headings []Heading) template.HTML {
+	var b strings.Builder
+	depth := 0
+	for _, h := range headings {
+		if h.Level < r.tocStart || h.Level > r.tocEnd {
+			continue
+		}
+		level := h.Level - r.tocStart + 1
+		switch {
+		case depth == 0:
+			b.WriteString("<ul>\n<li>")
+			depth = 1
+			for depth < level {
+				b.WriteString("<ul>\n<li>")
+				depth++
+			}
+		case level > depth:
+			for depth < level {
+				b.WriteString("\n<ul>\n<li>")
+				depth++
+			}
+		default:
+			for depth > level {
+				b.WriteString("</li>\n</ul>")
+				depth--
+			}
+			b.WriteString("</li>\n<li>")
+		}
+		fmt.Fprintf(&b, `<a href="#%s">%s</a>`, html.EscapeString(h.ID), html.EscapeString(h.Text))
+	}
+	if depth == 0 {
+		return ""
+	}
+	for ; depth > 0; depth-- {
+		b.WriteString("</li>\n</ul>")
+	}
+	return template.HTML(`<nav class="toc">` + "\n" + b.String() + "\n</nav>")
+}
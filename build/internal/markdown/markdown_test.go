@@ -0,0 +1,59 @@
+package markdown
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"ts-www/build/internal/config"
+)
+
+func TestRenderCodeBlockWarnings(t *testing.T) {
+	r, err := New(config.MarkdownConfig{Highlight: config.HighlightConfig{Enabled: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := "# Code\n\n```go {hl_lines=\"2-x\"}\nfunc main() {}\n```\n\ntext\n\n```go\nx := 1\n```\n\n```go {linenos=maybe}\ny := 2\n```\n"
+	result := r.Render([]byte(src))
+
+	var lines []int
+	for _, w := range result.Warnings {
+		lines = append(lines, w.Line)
+		if !strings.Contains(w.Error(), "code block") {
+			t.Errorf("warning %q doesn't name the code block", w)
+		}
+	}
+	if len(lines) != 2 || lines[0] != 3 || lines[1] != 13 {
+		t.Errorf("warnings are on lines %v, want [3 13]", lines)
+	}
+	// The blocks are still highlighted
+	if strings.Count(string(result.HTML), `<div class="highlight">`) != 3 {
+		t.Errorf("HTML = %s, want three highlighted blocks", result.HTML)
+	}
+}
+
+func TestRenderWithoutWarnings(t *testing.T) {
+	r, err := New(config.MarkdownConfig{Highlight: config.HighlightConfig{Enabled: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := r.Render([]byte("```go {linenos=true hl_lines=\"1\"}\nx := 1\n```\n\n    indented\n"))
+	if len(result.Warnings) != 0 {
+		t.Errorf("Warnings = %v, want none", result.Warnings)
+	}
+}
+
+func TestHeadings(t *testing.T) {
+	r, err := New(config.MarkdownConfig{Extensions: []string{"headingIDs"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := []byte("# Intro\n\n## Setup\n\ntext\n\n## Setup\n\n### Go `code` {#go}\n")
+	got := r.Headings(src)
+	if want := r.Render(src).Headings; !reflect.DeepEqual(got, want) {
+		t.Errorf("Headings = %v, want the headings Render lists, %v", got, want)
+	}
+	want := []Heading{{1, "intro", "Intro"}, {2, "setup", "Setup"}, {2, "setup-1", "Setup"}, {3, "go", "Go code"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Headings = %v, want %v", got, want)
+	}
+}
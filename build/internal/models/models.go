@@ -1,16 +1,113 @@
 package models
 
+import (
+	"encoding/json"
+	"html/template"
+	"time"
+)
+
 type Content struct {
-	Title           string `json:"title"`
-	Description     string `json:"description"`
-	Body            []byte `json:"body"`
-	Draft           bool   `json:"draft"`
-	URL             string `json:"URL"`
-	Featured        bool   `json:"featured,omitempty"`
-	Theme           string `json:"theme"`
-	Collection      string `json:"collection"`
-	Date            string `json:"date,omitempty"`
-	DataTitle       string `json:"data-title,omitempty"`
-	DataDescription string `json:"data-description,omitempty"`
-	DataImage       string `json:"data-image,omitempty"`
+	Title           string        `json:"title"`
+	Description     string        `json:"description"`
+	Body            []byte        `json:"body"`
+	HTML            template.HTML `json:"-"`                     // Rendered body
+	TOC             template.HTML `json:"toc,omitempty"`         // Table of contents of the rendered body
+	Summary         template.HTML `json:"summary,omitempty"`     // Body up to <!--more-->, or its first words as text
+	Truncated       bool          `json:"truncated,omitempty"`   // The summary leaves part of the body out
+	WordCount       int           `json:"wordCount,omitempty"`   // Words in the rendered body
+	ReadingTime     int           `json:"readingTime,omitempty"` // Minutes, rounded up
+	Draft           bool          `json:"draft"`
+	Future          bool          `json:"future,omitempty"`  // Publish date has not arrived yet
+	Expired         bool          `json:"expired,omitempty"` // Expiry date has passed
+	PublishDate     time.Time     `json:"publishDate"`
+	ExpiryDate      time.Time     `json:"expiryDate"`
+	URL             string        `json:"URL"`       // Front matter url, or the permalink when there is none
+	Permalink       string        `json:"permalink"` // Site path computed from the collection's permalink pattern
+	Slug            string        `json:"slug"`
+	Featured        bool          `json:"featured,omitempty"`
+	Theme           string        `json:"theme"`
+	Collection      string        `json:"collection"`
+	Date            time.Time     `json:"date"`
+	Lastmod         time.Time     `json:"lastmod"`            // Last modification, from the front matter or the file's git history
+	Unlisted        bool          `json:"unlisted,omitempty"` // Rendered but left out of the sitemap
+	DataTitle       string        `json:"data-title,omitempty"`
+	DataDescription string        `json:"data-description,omitempty"`
+	DataImage       string        `json:"data-image,omitempty"`
+	File            string        `json:"-"` // Source path relative to the content directory
+	BodyLine        int           `json:"-"` // Line of the source file the body starts on
+	DateErrors      []error       `json:"-"` // Front matter dates that didn't parse and were left zero
+
+	// FrontMatter is the front matter as it was written and KeyLines the line
+	// of each of its top-level keys, for checking it against a schema
+	FrontMatter map[string]interface{} `json:"-"`
+	KeyLines    map[string]int         `json:"-"`
+
+	Tags  []string            `json:"tags,omitempty"`  // Terms of the "tags" taxonomy
+	Terms map[string][]string `json:"terms,omitempty"` // Terms of every configured taxonomy, by taxonomy name
+
+	// Params holds the front matter keys the fields above don't cover, as
+	// decoded, so collections can carry fields of their own
+	Params map[string]interface{} `json:"params,omitempty"`
+
+	Related ContentList `json:"related,omitempty"` // Most similar content, best match first
+	Series  *Series     `json:"series,omitempty"`
+
+	// Neighbours in the collection by date: Prev was published before the
+	// content and Next after it. Pages have none.
+	Prev *Ref `json:"prev,omitempty"`
+	Next *Ref `json:"next,omitempty"`
+}
+
+// ContentList is a list of other content items. It marshals each item as a
+// short summary rather than in full, so items can refer to each other without
+// cycles while changes to what a page shows of them are still seen.
+type ContentList []*Content
+
+func (l ContentList) MarshalJSON() ([]byte, error) {
+	summaries := make([]summary, len(l))
+	for i, c := range l {
+		summaries[i] = summarize(c)
+	}
+	return json.Marshal(summaries)
+}
+
+// Ref points at a single other content item and marshals like the items of
+// a ContentList.
+type Ref struct {
+	*Content
+}
+
+func (r Ref) MarshalJSON() ([]byte, error) {
+	return json.Marshal(summarize(r.Content))
+}
+
+type summary struct {
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	URL         string    `json:"URL"`
+	Permalink   string    `json:"permalink"`
+	Date        time.Time `json:"date"`
+}
+
+func summarize(c *Content) summary {
+	return summary{Title: c.Title, Description: c.Description, URL: c.URL, Permalink: c.Permalink, Date: c.Date}
+}
+
+// Series places content within a multi-part series. Name and Order come from
+// the front matter; the rest is filled in once the whole site is loaded.
+type Series struct {
+	Name      string      `json:"name"`
+	Order     int         `json:"order,omitempty"` // Explicit position from the front matter, 0 when unset
+	Slug      string      `json:"slug"`
+	Permalink string      `json:"permalink"` // Overview page of the series
+	Pages     ContentList `json:"pages"`     // Every part, in order
+	Part      int         `json:"part"`      // Position of the content in Pages, starting at 1
+	Prev      *Ref        `json:"prev,omitempty"`
+	Next      *Ref        `json:"next,omitempty"`
+}
+
+// Published reports whether the content would be rendered without any of the
+// draft, future or expired preview modes.
+func (c *Content) Published() bool {
+	return !c.Draft && !c.Future && !c.Expired
 }
@@ -131,7 +131,7 @@ func processFile(filePath string) (ContentItem, error) {
 		content.Description = description
 	}
 	if date, ok := frontMatter["date"].(string); ok {
-		content.Date = date
+		content.Date = utils.ParseDate(date)
 	}
 
 	content.Collection = filepath.Base(filepath.Dir(filePath))
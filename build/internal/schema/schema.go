@@ -0,0 +1,170 @@
+// Package schema validates front matter against the schema of a collection.
+package schema
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"ts-www/build/internal/config"
+	"ts-www/build/internal/utils"
+)
+
+// Violation is a front matter value that doesn't follow the schema.
+type Violation struct {
+	Key     string // Top-level key the violation is about
+	Message string
+}
+
+func (v *Violation) Error() string {
+	return v.Message
+}
+
+// Validate checks front matter against a schema, returning its violations
+// ordered by key.
+func Validate(s config.Schema, fm map[string]interface{}) []*Violation {
+	var violations []*Violation
+	for key, field := range s.Fields {
+		value, ok := fm[key]
+		if !ok || empty(value) {
+			if field.Required {
+				violations = append(violations, &Violation{Key: key, Message: fmt.Sprintf("%s is required", key)})
+			}
+			continue
+		}
+		if err := check(field, field.Type, value); err != nil {
+			violations = append(violations, &Violation{Key: key, Message: fmt.Sprintf("%s %v", key, err)})
+		}
+	}
+	if s.Strict {
+		for key := range fm {
+			if _, ok := s.Fields[key]; !ok {
+				violations = append(violations, &Violation{Key: key, Message: fmt.Sprintf("%s is not a field of the schema", key)})
+			}
+		}
+	}
+	sort.SliceStable(violations, func(i, j int) bool { return violations[i].Key < violations[j].Key })
+	return violations
+}
+
+// empty reports whether a value is left blank, as in "description:" or
+// "tags: []".
+func empty(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return strings.TrimSpace(v) == ""
+	case []interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+// check checks a value, or an item of a list, against the type t and the
+// allowed values and formats of a field.
+func check(field config.FieldSchema, t string, value interface{}) error {
+	if list, ok := value.([]interface{}); ok && t == "list" {
+		for i, item := range list {
+			if err := check(field, field.Items, item); err != nil {
+				return fmt.Errorf("item %d: %w", i+1, err)
+			}
+		}
+		return nil
+	}
+
+	if err := checkType(field, t, value); err != nil {
+		return err
+	}
+	if len(field.Values) > 0 && t != "list" && t != "map" {
+		written := fmt.Sprint(value)
+		for _, allowed := range field.Values {
+			if written == allowed {
+				return nil
+			}
+		}
+		return fmt.Errorf("must be one of %s, got %q", strings.Join(field.Values, ", "), written)
+	}
+	return nil
+}
+
+func checkType(field config.FieldSchema, t string, value interface{}) error {
+	ok := true
+	switch t {
+	case "":
+	case "string":
+		_, ok = value.(string)
+	case "int":
+		_, ok = value.(int)
+	case "float":
+		switch value.(type) {
+		case int, float64:
+		default:
+			ok = false
+		}
+	case "bool":
+		_, ok = value.(bool)
+	case "list":
+		_, ok = value.([]interface{})
+	case "map":
+		_, ok = value.(map[string]interface{})
+	case "date":
+		s, isString := value.(string)
+		if !isString {
+			ok = false
+			break
+		}
+		return checkDate(field.Formats, s)
+	}
+	if !ok {
+		return fmt.Errorf("must be %s, got %s", describe(t), describeValue(value))
+	}
+	return nil
+}
+
+// checkDate accepts a date written in any of the formats or, when there are
+// none, any date content can have.
+func checkDate(formats []string, s string) error {
+	if len(formats) == 0 {
+		_, err := utils.ParseTime(s, time.UTC)
+		return err
+	}
+	for _, layout := range formats {
+		if _, err := time.Parse(layout, s); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("%q is not a date in the format %s", s, strings.Join(formats, " or "))
+}
+
+func describe(t string) string {
+	switch t {
+	case "int":
+		return "a whole number"
+	case "float":
+		return "a number"
+	case "bool":
+		return "true or false"
+	case "map":
+		return "a map of keys"
+	}
+	return "a " + t
+}
+
+func describeValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return fmt.Sprintf("%q", v)
+	case int:
+		return "a whole number"
+	case float64:
+		return "a number"
+	case bool:
+		return "true or false"
+	case []interface{}:
+		return "a list"
+	case map[string]interface{}:
+		return "a map of keys"
+	}
+	return fmt.Sprintf("%T", value)
+}
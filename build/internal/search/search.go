@@ -0,0 +1,204 @@
+package search
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Version is the version of the index format, bumped whenever a change would
+// break existing readers.
+const Version = 1
+
+// Index is the search index written during the build. It is compact JSON so
+// that browsers can fetch it, and is queried the same way by Search.
+type Index struct {
+	Version   int        `json:"version"`
+	Documents []Document `json:"documents"`
+}
+
+// Document is a single page in the index. Terms holds the page body as
+// token frequencies, which is all ranking needs and much smaller than the
+// text itself.
+type Document struct {
+	Title       string         `json:"title"`
+	Description string         `json:"description,omitempty"`
+	Collection  string         `json:"collection"`
+	URL         string         `json:"url"`
+	Length      int            `json:"length"` // Number of tokens in the body
+	Terms       map[string]int `json:"terms"`
+}
+
+// Result is a document matching a query and its score.
+type Result struct {
+	Document *Document
+	Score    float64
+}
+
+// NewDocument builds an index document from a page and its rendered HTML body.
+func NewDocument(title, description, collection, url, body string) Document {
+	doc := Document{Title: title, Description: description, Collection: collection, URL: url, Terms: make(map[string]int)}
+	for _, token := range Tokenize(PlainText(body)) {
+		doc.Terms[token]++
+		doc.Length++
+	}
+	return doc
+}
+
+var (
+	blockTagPattern = regexp.MustCompile(`(?i)</?(address|article|aside|blockquote|br|dd|div|dl|dt|figcaption|figure|footer|h[1-6]|header|hr|li|nav|ol|p|pre|section|table|td|th|tr|ul)\b[^>]*>`)
+	tagPattern      = regexp.MustCompile(`(?s)<[^>]*>`)
+)
+
+// PlainText strips the tags and entities from rendered HTML. Block elements
+// separate words while inline ones don't, so "<em>para</em>." stays "para.".
+func PlainText(body string) string {
+	text := tagPattern.ReplaceAllString(blockTagPattern.ReplaceAllString(body, " "), "")
+	return strings.Join(strings.Fields(html.UnescapeString(text)), " ")
+}
+
+// stopWords are too common to tell documents apart, so they are neither
+// indexed nor searched for.
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "but": true,
+	"by": true, "for": true, "if": true, "in": true, "into": true, "is": true, "it": true, "no": true,
+	"not": true, "of": true, "on": true, "or": true, "so": true, "that": true, "the": true, "their": true,
+	"then": true, "there": true, "these": true, "they": true, "this": true, "to": true, "was": true,
+	"will": true, "with": true,
+}
+
+// Tokenize splits text into lower case words, dropping stop words. Words are
+// runs of letters and digits; apostrophes inside a word are dropped so that
+// "I'll" and "ill" index alike.
+func Tokenize(text string) []string {
+	text = strings.NewReplacer("'", "", "’", "").Replace(strings.ToLower(text))
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	tokens := words[:0]
+	for _, word := range words {
+		if !stopWords[word] {
+			tokens = append(tokens, word)
+		}
+	}
+	return tokens
+}
+
+// Read decodes an index, refusing versions it doesn't understand.
+func Read(r io.Reader) (*Index, error) {
+	var ix Index
+	if err := json.NewDecoder(r).Decode(&ix); err != nil {
+		return nil, err
+	}
+	if ix.Version != Version {
+		return nil, fmt.Errorf("unsupported search index version %d", ix.Version)
+	}
+	return &ix, nil
+}
+
+// Write encodes the index without indentation.
+func (ix *Index) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return enc.Encode(ix)
+}
+
+// BM25 parameters: k1 limits how much repeating a term counts, b how much
+// long documents are penalised.
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// Search ranks the documents against query, best first, returning at most
+// limit results, or all of them when limit is zero. Body matches are scored
+// with BM25; matches in the title and description weigh more. The last query
+// word also matches as a prefix, so partially typed words find results.
+func (ix *Index) Search(query string, limit int) []Result {
+	words := Tokenize(query)
+	if len(words) == 0 || len(ix.Documents) == 0 {
+		return nil
+	}
+
+	var avgLength float64
+	for _, doc := range ix.Documents {
+		avgLength += float64(doc.Length)
+	}
+	avgLength = math.Max(avgLength/float64(len(ix.Documents)), 1)
+
+	scores := make([]float64, len(ix.Documents))
+	for i, word := range words {
+		prefix := i == len(words)-1
+		tfs := make([]float64, len(ix.Documents))
+		df := 0
+		for j := range ix.Documents {
+			doc := &ix.Documents[j]
+			tfs[j] = termFrequency(doc, word, prefix)
+			if tfs[j] > 0 {
+				df++
+			}
+		}
+
+		n := float64(len(ix.Documents))
+		idf := math.Log(1 + (n-float64(df)+0.5)/(float64(df)+0.5))
+		for j := range ix.Documents {
+			doc := &ix.Documents[j]
+			tf := tfs[j]
+			if tf > 0 {
+				scores[j] += idf * tf * (k1 + 1) / (tf + k1*(1-b+b*float64(doc.Length)/avgLength))
+			}
+			scores[j] += idf * (3*fieldMatch(doc.Title, word, prefix) + 1.5*fieldMatch(doc.Description, word, prefix))
+		}
+	}
+
+	var results []Result
+	for i := range ix.Documents {
+		if scores[i] > 0 {
+			results = append(results, Result{Document: &ix.Documents[i], Score: scores[i]})
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Document.Title < results[j].Document.Title
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// termFrequency counts a word in a document body. Prefix matches count half.
+func termFrequency(doc *Document, word string, prefix bool) float64 {
+	tf := float64(doc.Terms[word])
+	if prefix {
+		for term, count := range doc.Terms {
+			if term != word && strings.HasPrefix(term, word) {
+				tf += float64(count) / 2
+			}
+		}
+	}
+	return tf
+}
+
+// fieldMatch returns 1 if a short field such as the title contains the word,
+// one half if it only contains it as a prefix, and 0 otherwise.
+func fieldMatch(field, word string, prefix bool) float64 {
+	match := 0.0
+	for _, token := range Tokenize(field) {
+		if token == word {
+			return 1
+		}
+		if prefix && strings.HasPrefix(token, word) {
+			match = 0.5
+		}
+	}
+	return match
+}
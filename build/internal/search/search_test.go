@@ -0,0 +1,179 @@
+package search
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"Hello, World!", []string{"hello", "world"}},
+		{"The cat and the hat", []string{"cat", "hat"}},
+		{"I'll go; you’re staying", []string{"ill", "go", "youre", "staying"}},
+		{"Go 1.21 ships généricité", []string{"go", "1", "21", "ships", "généricité"}},
+		{"", nil},
+		{"a the of", nil},
+	}
+	for _, tt := range tests {
+		got := Tokenize(tt.text)
+		if len(got) == 0 && len(tt.want) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Tokenize(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	tests := []struct {
+		html string
+		want string
+	}{
+		{"<p>one</p><p>two</p>", "one two"},
+		{"<p>A <em>para</em>.</p>", "A para."},
+		{"<h2 id=\"x\">Title</h2>\n<ul><li>a</li><li>b</li></ul>", "Title a b"},
+		{"<p>fish &amp; chips&nbsp;today</p>", "fish & chips today"},
+		{"line<br>break", "line break"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := PlainText(tt.html); got != tt.want {
+			t.Errorf("PlainText(%q) = %q, want %q", tt.html, got, tt.want)
+		}
+	}
+}
+
+func TestNewDocument(t *testing.T) {
+	doc := NewDocument("Intro", "First post", "writing", "/writing/intro", "<h1>Hello</h1><p>Hello <em>static</em> sites &amp; the web</p>")
+	want := map[string]int{"hello": 2, "static": 1, "sites": 1, "web": 1}
+	if !reflect.DeepEqual(doc.Terms, want) {
+		t.Errorf("Terms = %v, want %v", doc.Terms, want)
+	}
+	if doc.Length != 5 {
+		t.Errorf("Length = %d, want 5", doc.Length)
+	}
+	if doc.Title != "Intro" || doc.Description != "First post" || doc.Collection != "writing" || doc.URL != "/writing/intro" {
+		t.Errorf("fields = %+v", doc)
+	}
+}
+
+func TestWriteRead(t *testing.T) {
+	ix := &Index{Version: Version, Documents: []Document{
+		NewDocument("Intro", "First <post>", "writing", "/writing/intro", "<p>hello world</p>"),
+		NewDocument("Projects", "", "page", "/projects", "<p>go forth</p>"),
+	}}
+	var buf bytes.Buffer
+	if err := ix.Write(&buf); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if !strings.Contains(buf.String(), "First <post>") {
+		t.Errorf("Write escaped HTML: %s", buf.String())
+	}
+	got, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if !reflect.DeepEqual(got, ix) {
+		t.Errorf("Read = %+v, want %+v", got, ix)
+	}
+}
+
+func TestReadRejectsUnknownVersion(t *testing.T) {
+	for _, index := range []string{`{"version": 2, "documents": []}`, `{"documents": []}`} {
+		if _, err := Read(strings.NewReader(index)); err == nil || !strings.Contains(err.Error(), "version") {
+			t.Errorf("Read(%s) error = %v, want an unsupported version", index, err)
+		}
+	}
+	if _, err := Read(strings.NewReader("not json")); err == nil {
+		t.Error("Read accepted invalid JSON")
+	}
+}
+
+func testIndex() *Index {
+	return &Index{Version: Version, Documents: []Document{
+		NewDocument("Static sites", "Building a static site generator", "writing", "/writing/static", "<p>A generator written in Go renders markdown.</p>"),
+		NewDocument("Cooking", "Recipes", "writing", "/writing/cooking", "<p>Pasta and generators of flavour. Markdown recipes.</p>"),
+		NewDocument("Go notes", "", "writing", "/writing/go", "<p>Go go go: goroutines, generics and the go tool.</p>"),
+		NewDocument("About", "", "page", "/about", "<p>Nothing to see.</p>"),
+	}}
+}
+
+func titles(results []Result) []string {
+	var list []string
+	for _, r := range results {
+		list = append(list, r.Document.Title)
+	}
+	return list
+}
+
+func TestSearchRanking(t *testing.T) {
+	ix := testIndex()
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		// The title and description match outweigh a body match
+		{"static", []string{"Static sites"}},
+		// Body matches alone favour the shorter body
+		{"markdown", []string{"Cooking", "Static sites"}},
+		// Repeated terms rank higher
+		{"go", []string{"Go notes", "Static sites"}},
+		{"nothing", []string{"About"}},
+		{"zebra", nil},
+		{"the and", nil},
+	}
+	for _, tt := range tests {
+		got := titles(ix.Search(tt.query, 0))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Search(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+
+	results := ix.Search("go", 0)
+	if len(results) > 1 && results[0].Score <= results[1].Score {
+		t.Errorf("scores are not descending: %v, %v", results[0].Score, results[1].Score)
+	}
+}
+
+func TestSearchPrefix(t *testing.T) {
+	ix := testIndex()
+	// Only the last word matches as a prefix
+	if got := titles(ix.Search("gene", 0)); !reflect.DeepEqual(got, []string{"Static sites", "Cooking", "Go notes"}) {
+		t.Errorf("Search(gene) = %q", got)
+	}
+	if got := titles(ix.Search("gene markdown", 0)); !reflect.DeepEqual(got, []string{"Cooking", "Static sites"}) {
+		t.Errorf("Search(gene markdown) = %q", got)
+	}
+	// An exact match beats a prefix match
+	exact := ix.Search("generator", 0)
+	if len(exact) == 0 || exact[0].Document.Title != "Static sites" {
+		t.Errorf("Search(generator) = %q, want Static sites first", titles(exact))
+	}
+}
+
+func TestSearchLimit(t *testing.T) {
+	ix := testIndex()
+	all := ix.Search("gen", 0)
+	if len(all) < 2 {
+		t.Fatalf("Search(gen) = %q, want several results", titles(all))
+	}
+	for _, limit := range []int{1, 2, len(all) + 5} {
+		got := ix.Search("gen", limit)
+		want := all
+		if limit < len(all) {
+			want = all[:limit]
+		}
+		if !reflect.DeepEqual(titles(got), titles(want)) {
+			t.Errorf("Search(gen, %d) = %q, want %q", limit, titles(got), titles(want))
+		}
+	}
+	if got := (&Index{Version: Version}).Search("go", 3); got != nil {
+		t.Errorf("Search on an empty index = %v, want none", got)
+	}
+}
@@ -0,0 +1,310 @@
+// Package shortcode expands shortcodes in markdown before it is rendered.
+//
+// A shortcode calls a template in templates/shortcodes with named
+// parameters, on its own or around inner content:
+//
+//	{{< figure src="/public/images/profile.jpg" caption="Me" >}}
+//	{{< callout type="note" >}}Inner **markdown**{{< /callout >}}
+//
+// A shortcode can be written literally by commenting it out, as in
+// {{</* figure */>}}.
+package shortcode
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"strconv"
+	"strings"
+	"ts-www/build/internal/models"
+	"unicode"
+)
+
+const (
+	openDelim  = "{{<"
+	closeDelim = ">}}"
+)
+
+// Error is a shortcode that could not be expanded.
+type Error struct {
+	Line int // Line of the body the shortcode starts on, counted from 1
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Call is the data a shortcode template is executed with.
+type Call struct {
+	Name   string
+	Params map[string]string
+	Inner  string // Content between the opening and closing tag, with its own shortcodes expanded
+	Page   *models.Content
+	Line   int
+
+	ref func(string) (*models.Content, error)
+}
+
+// Get returns a parameter, or an empty string when it wasn't given.
+func (c *Call) Get(key string) string {
+	return c.Params[key]
+}
+
+// Ref returns the content a link target such as "projects/go-forth" names.
+// Templates fail when there is no such content.
+func (c *Call) Ref(target string) (*models.Content, error) {
+	if c.ref == nil {
+		return nil, fmt.Errorf("no content at %s", target)
+	}
+	return c.ref(target)
+}
+
+// Expander expands shortcodes with a set of templates, named after their
+// files such as "figure.html".
+type Expander struct {
+	Templates *template.Template
+	Ref       func(target string) (*models.Content, error) // Looks up the content shortcodes refer to
+}
+
+// Expand returns the body of a page with its shortcodes replaced by their
+// output. Shortcodes that fail are reported and left out.
+func (x *Expander) Expand(page *models.Content, body []byte) ([]byte, []error) {
+	if !bytes.Contains(body, []byte(openDelim)) {
+		return body, nil
+	}
+	e := &expansion{Expander: x, page: page}
+	out := e.expand(string(body), 1)
+	return []byte(out), e.errs
+}
+
+type expansion struct {
+	*Expander
+	page *models.Content
+	errs []error
+}
+
+// tag is a shortcode tag found in a body.
+type tag struct {
+	start, end int // Offsets of "{{<" and just past ">}}"
+	name       string
+	params     map[string]string
+	closing    bool // {{< /name >}}
+	selfClosed bool // {{< name />}}
+	escaped    bool // {{</* name */>}}, written out literally
+	text       string
+}
+
+// expand expands the shortcodes of src, whose first line is line of the body.
+func (e *expansion) expand(src string, line int) string {
+	var b strings.Builder
+	pos := 0
+	for {
+		t, err := nextTag(src, pos)
+		if t == nil {
+			if err != nil {
+				start := pos + strings.Index(src[pos:], openDelim)
+				e.errs = append(e.errs, &Error{Line: line + strings.Count(src[:start], "\n"), Err: err})
+			}
+			b.WriteString(src[pos:])
+			return b.String()
+		}
+		b.WriteString(src[pos:t.start])
+		pos = t.end
+		tagLine := line + strings.Count(src[:t.start], "\n")
+
+		switch {
+		case err != nil:
+			// Skip the content of a broken shortcode too, so its closing tag
+			// isn't reported as well
+			if !t.closing && !t.selfClosed && validName(t.name) {
+				if end := matchingClose(src, t); end != nil {
+					pos = end.end
+				}
+			}
+			e.errs = append(e.errs, &Error{Line: tagLine, Err: err})
+			continue
+		case t.escaped:
+			b.WriteString(openDelim + t.text + closeDelim)
+			continue
+		case t.closing:
+			e.errs = append(e.errs, &Error{Line: tagLine, Err: fmt.Errorf("closing tag for %s without an opening tag", t.name)})
+			continue
+		}
+
+		call := &Call{Name: t.name, Params: t.params, Page: e.page, Line: tagLine, ref: e.Ref}
+		if !t.selfClosed {
+			if end := matchingClose(src, t); end != nil {
+				innerLine := tagLine + strings.Count(src[t.start:t.end], "\n")
+				call.Inner = e.expand(src[t.end:end.start], innerLine)
+				pos = end.end
+			}
+		}
+
+		out, err := e.execute(call)
+		if err != nil {
+			e.errs = append(e.errs, &Error{Line: tagLine, Err: err})
+			continue
+		}
+		b.WriteString(out)
+	}
+}
+
+// execute renders a shortcode's template.
+func (e *expansion) execute(call *Call) (string, error) {
+	var tmpl *template.Template
+	if e.Templates != nil {
+		tmpl = e.Templates.Lookup(call.Name + ".html")
+	}
+	if tmpl == nil {
+		return "", fmt.Errorf("unknown shortcode %s, expected templates/shortcodes/%s.html", call.Name, call.Name)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, call); err != nil {
+		return "", fmt.Errorf("shortcode %s: %w", call.Name, err)
+	}
+	return b.String(), nil
+}
+
+// matchingClose finds the closing tag of t, skipping nested shortcodes of the
+// same name, or returns nil when t stands on its own.
+func matchingClose(src string, t *tag) *tag {
+	depth := 0
+	for pos := t.end; ; {
+		next, err := nextTag(src, pos)
+		if next == nil {
+			return nil
+		}
+		pos = next.end
+		if err != nil || next.escaped || next.name != t.name {
+			continue
+		}
+		switch {
+		case next.closing && depth == 0:
+			return next
+		case next.closing:
+			depth--
+		case !next.selfClosed:
+			depth++
+		}
+	}
+}
+
+// nextTag finds the first tag at or after pos. A tag that doesn't parse is
+// returned along with the error, so the caller can skip it; a tag that is
+// never closed returns a nil tag and an error.
+func nextTag(src string, pos int) (*tag, error) {
+	start := strings.Index(src[pos:], openDelim)
+	if start < 0 {
+		return nil, nil
+	}
+	start += pos
+	end := strings.Index(src[start+len(openDelim):], closeDelim)
+	if end < 0 {
+		return nil, fmt.Errorf("shortcode tag is never closed with %s", closeDelim)
+	}
+	text := src[start+len(openDelim) : start+len(openDelim)+end]
+	t := &tag{start: start, end: start + len(openDelim) + end + len(closeDelim), text: text}
+
+	inner := strings.TrimSpace(text)
+	if strings.HasPrefix(inner, "/*") && strings.HasSuffix(inner, "*/") {
+		t.escaped = true
+		t.text = strings.TrimSuffix(strings.TrimPrefix(inner, "/*"), "*/")
+		return t, nil
+	}
+	if strings.HasPrefix(inner, "/") {
+		t.closing = true
+		t.name = strings.TrimSpace(inner[1:])
+		if !validName(t.name) {
+			return t, fmt.Errorf("invalid shortcode name %q", t.name)
+		}
+		return t, nil
+	}
+	if strings.HasSuffix(inner, "/") {
+		t.selfClosed = true
+		inner = strings.TrimSpace(strings.TrimSuffix(inner, "/"))
+	}
+
+	nameEnd := strings.IndexFunc(inner, unicode.IsSpace)
+	if nameEnd < 0 {
+		nameEnd = len(inner)
+	}
+	t.name = inner[:nameEnd]
+	if !validName(t.name) {
+		return t, fmt.Errorf("invalid shortcode name %q", t.name)
+	}
+	params, err := parseParams(inner[nameEnd:])
+	if err != nil {
+		return t, fmt.Errorf("shortcode %s: %w", t.name, err)
+	}
+	t.params = params
+	return t, nil
+}
+
+// validName accepts the names templates can have: letters, digits, dashes
+// and underscores.
+func validName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if r != '-' && r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseParams reads key=value pairs. Values are bare words or quoted with
+// double quotes, which may contain Go escapes, or single quotes.
+func parseParams(s string) (map[string]string, error) {
+	params := make(map[string]string)
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimSpace(s) {
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 || strings.IndexFunc(s[:eq], unicode.IsSpace) >= 0 {
+			return nil, fmt.Errorf("expected key=value parameters, got %q", s)
+		}
+		key := s[:eq]
+		s = s[eq+1:]
+		if _, ok := params[key]; ok {
+			return nil, fmt.Errorf("parameter %s is given twice", key)
+		}
+
+		var value string
+		switch {
+		case strings.HasPrefix(s, `"`):
+			end := 1
+			for ; end < len(s) && s[end] != '"'; end++ {
+				if s[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(s) {
+				return nil, fmt.Errorf("parameter %s: unterminated string", key)
+			}
+			var err error
+			if value, err = strconv.Unquote(s[:end+1]); err != nil {
+				return nil, fmt.Errorf("parameter %s: %w", key, err)
+			}
+			s = s[end+1:]
+		case strings.HasPrefix(s, "'"):
+			end := strings.IndexByte(s[1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("parameter %s: unterminated string", key)
+			}
+			value, s = s[1:end+1], s[end+2:]
+		default:
+			end := strings.IndexFunc(s, unicode.IsSpace)
+			if end < 0 {
+				end = len(s)
+			}
+			value, s = s[:end], s[end:]
+		}
+		params[key] = value
+	}
+	return params, nil
+}
@@ -0,0 +1,167 @@
+package shortcode
+
+import (
+	"html/template"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNextTag(t *testing.T) {
+	tests := []struct {
+		name  string
+		src   string
+		want  *tag
+		error bool
+	}{
+		{"none", "no shortcodes here", nil, false},
+		{
+			"with parameters", `a {{< figure src="/a.png" caption='Me' >}} b`,
+			&tag{start: 2, end: 42, name: "figure", params: map[string]string{"src": "/a.png", "caption": "Me"}, text: ` figure src="/a.png" caption='Me' `},
+			false,
+		},
+		{
+			"without parameters", "{{<note>}}",
+			&tag{start: 0, end: 10, name: "note", params: map[string]string{}, text: "note"},
+			false,
+		},
+		{
+			"self-closed", "{{< br />}}",
+			&tag{start: 0, end: 11, name: "br", params: map[string]string{}, selfClosed: true, text: " br /"},
+			false,
+		},
+		{
+			"closing", "{{< /callout >}}",
+			&tag{start: 0, end: 16, name: "callout", closing: true, text: " /callout "},
+			false,
+		},
+		{
+			"escaped", "{{</* figure src=x */>}}",
+			&tag{start: 0, end: 24, escaped: true, text: " figure src=x "},
+			false,
+		},
+		{"never closed", "text {{< figure", nil, true},
+		{"invalid name", "{{< fig.ure >}}", &tag{start: 0, end: 15, name: "fig.ure", text: " fig.ure "}, true},
+		{"invalid closing name", "{{< / >}}", &tag{start: 0, end: 9, closing: true, text: " / "}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := nextTag(tt.src, 0)
+			if (err != nil) != tt.error {
+				t.Errorf("error = %v, want error %v", err, tt.error)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("nextTag = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextTagFrom(t *testing.T) {
+	src := "{{< a >}} and {{< b >}}"
+	got, err := nextTag(src, 1)
+	if err != nil || got == nil || got.name != "b" || got.start != 14 {
+		t.Errorf("nextTag from 1 = %+v, %v, want b at 14", got, err)
+	}
+}
+
+func TestParseParams(t *testing.T) {
+	tests := []struct {
+		params string
+		want   map[string]string
+		error  string
+	}{
+		{"", map[string]string{}, ""},
+		{`  a=1   b=two `, map[string]string{"a": "1", "b": "two"}, ""},
+		{`title="Hello, \"world\"\n"`, map[string]string{"title": "Hello, \"world\"\n"}, ""},
+		{`path='C:\dir' empty=""`, map[string]string{"path": `C:\dir`, "empty": ""}, ""},
+		{`a="x y" b='p q'`, map[string]string{"a": "x y", "b": "p q"}, ""},
+		{"bare", nil, "expected key=value"},
+		{"=x", nil, "expected key=value"},
+		{"a b=1", nil, "expected key=value"},
+		{"a=1 a=2", nil, "given twice"},
+		{`a="open`, nil, "unterminated"},
+		{`a='open`, nil, "unterminated"},
+		{`a="\q"`, nil, "parameter a"},
+	}
+	for _, tt := range tests {
+		got, err := parseParams(tt.params)
+		if tt.error != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("parseParams(%q) error = %v, want %q", tt.params, err, tt.error)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseParams(%q): %v", tt.params, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseParams(%q) = %v, want %v", tt.params, got, tt.want)
+		}
+	}
+}
+
+func TestMatchingClose(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want int // Start of the closing tag, -1 for none
+	}{
+		{"simple", "{{< c >}}inner{{< /c >}}", 14},
+		{"nested", "{{< c >}}{{< c >}}x{{< /c >}}{{< /c >}}", 29},
+		{"self-closed inside", "{{< c >}}{{< c />}}{{< /c >}}", 19},
+		{"other shortcodes inside", "{{< c >}}{{< d >}}{{< /d >}}{{< /c >}}", 28},
+		{"escaped closing tag", "{{< c >}}{{</* /c */>}}{{< /c >}}", 23},
+		{"standalone", "{{< c >}} text {{< d >}}", -1},
+		{"unclosed nested", "{{< c >}}{{< c >}}{{< /c >}}", -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			open, err := nextTag(tt.src, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := matchingClose(tt.src, open)
+			switch {
+			case tt.want < 0 && got != nil:
+				t.Errorf("matchingClose = tag at %d, want none", got.start)
+			case tt.want >= 0 && (got == nil || got.start != tt.want):
+				t.Errorf("matchingClose = %+v, want a tag at %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpand(t *testing.T) {
+	templates := template.Must(template.New("note.html").Parse(`<aside class="{{ .Get "type" }}">{{ .Inner }}</aside>`))
+	template.Must(templates.New("wrap.html").Parse(`({{ .Inner }})`))
+	x := &Expander{Templates: templates}
+	tests := []struct {
+		body   string
+		want   string
+		errors []int // Lines of the errors
+	}{
+		{"plain", "plain", nil},
+		{`{{< note type="tip" >}}hi{{< /note >}}`, `<aside class="tip">hi</aside>`, nil},
+		{"{{< wrap >}}a{{< wrap >}}b{{< /wrap >}}{{< /wrap >}}", "(a(b))", nil},
+		{"{{</* note */>}}", "{{< note >}}", nil},
+		{"a\n{{< missing >}}\nb", "a\n\nb", []int{2}},
+		{"a\n\n{{< /note >}}", "a\n\n", []int{3}},
+		{"a\n{{< note x=\"open >}}skipped{{< /note >}}b", "a\nb", []int{2}},
+		{"a\n{{< note", "a\n{{< note", []int{2}},
+	}
+	for _, tt := range tests {
+		got, errs := x.Expand(nil, []byte(tt.body))
+		if string(got) != tt.want {
+			t.Errorf("Expand(%q) = %q, want %q", tt.body, got, tt.want)
+		}
+		var lines []int
+		for _, err := range errs {
+			lines = append(lines, err.(*Error).Line)
+		}
+		if !reflect.DeepEqual(lines, tt.errors) {
+			t.Errorf("Expand(%q) errors %v on lines %v, want lines %v", tt.body, errs, lines, tt.errors)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package site
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"ts-www/build/internal/models"
+	"ts-www/build/internal/utils"
+)
+
+// Archive is the data of a date archive page.
+type Archive struct {
+	Collection string       // Empty for the site-wide archive
+	Permalink  string       // Permalink of the archive's index of years
+	Years      []*YearGroup // Every year with content, newest first
+	Year       *YearGroup   // Set on year and month pages
+	Month      *MonthGroup  // Set on month pages
+}
+
+// YearGroup is the dated content of one year.
+type YearGroup struct {
+	Year      int
+	Permalink string        // Empty when grouped by a template helper
+	Months    []*MonthGroup // Newest first
+	Pages     []*models.Content
+}
+
+// MonthGroup is the dated content of one month.
+type MonthGroup struct {
+	Year      int
+	Month     time.Month
+	Permalink string // Empty when grouped by a template helper
+	Pages     []*models.Content
+}
+
+// GroupByYear groups content by the year and month of its date, newest first.
+// Content without a valid date is left out.
+func GroupByYear(pages []*models.Content) []*YearGroup {
+	dated := make([]*models.Content, 0, len(pages))
+	for _, page := range pages {
+		if !page.Date.IsZero() {
+			dated = append(dated, page)
+		}
+	}
+	sortByDate(dated)
+
+	var years []*YearGroup
+	for _, page := range dated {
+		date := page.Date
+		if len(years) == 0 || years[len(years)-1].Year != date.Year() {
+			years = append(years, &YearGroup{Year: date.Year()})
+		}
+		year := years[len(years)-1]
+		if len(year.Months) == 0 || year.Months[len(year.Months)-1].Month != date.Month() {
+			year.Months = append(year.Months, &MonthGroup{Year: date.Year(), Month: date.Month()})
+		}
+		month := year.Months[len(year.Months)-1]
+		year.Pages = append(year.Pages, page)
+		month.Pages = append(month.Pages, page)
+	}
+	return years
+}
+
+// GroupByMonth groups content by month, newest first, across years.
+func GroupByMonth(pages []*models.Content) []*MonthGroup {
+	var months []*MonthGroup
+	for _, year := range GroupByYear(pages) {
+		months = append(months, year.Months...)
+	}
+	return months
+}
+
+// contentList accepts the content lists templates come across, such as .Feed,
+// .Term.Pages or .Page.Related, so the grouping helpers work on any of them.
+func contentList(list interface{}) ([]*models.Content, error) {
+	switch l := list.(type) {
+	case nil:
+		return nil, nil
+	case []*models.Content:
+		return l, nil
+	case models.ContentList:
+		return l, nil
+	case []models.Content:
+		pages := make([]*models.Content, len(l))
+		for i := range l {
+			pages[i] = &l[i]
+		}
+		return pages, nil
+	}
+	return nil, fmt.Errorf("cannot group %T by date", list)
+}
+
+// buildArchives registers the site-wide archive and the archives of the
+// configured collections: an index of years, a page per year and a page per
+// month, all rendered with the archive template.
+func (s *Site) buildArchives() []error {
+	ac := s.Config.Archives
+	var collections []string
+	if ac.SiteWide {
+		collections = append(collections, "")
+	}
+	collections = append(collections, ac.Collections...)
+	if len(collections) == 0 {
+		return nil
+	}
+
+	template := orDefault(ac.Template, "archive")
+	if utils.Templates.Lookup(template) == nil {
+		return []error{&FileError{Path: "config.json", Err: fmt.Errorf("archives: template %s not found", template)}}
+	}
+
+	var errs []error
+	for _, collection := range collections {
+		var pages []*models.Content
+		for _, page := range s.Pages {
+			if page.Collection != "page" && (collection == "" || page.Collection == collection) {
+				pages = append(pages, page)
+			}
+		}
+
+		name := "archive"
+		if collection != "" {
+			name = collection + " archive"
+		}
+		archive := &Archive{
+			Collection: collection,
+			Permalink:  s.archivePermalink(orDefault(ac.Permalink, "/:collection/archive"), collection, 0, 0),
+			Years:      GroupByYear(pages),
+		}
+		for _, year := range archive.Years {
+			year.Permalink = s.archivePermalink(orDefault(ac.YearPermalink, "/:collection/archive/:year"), collection, year.Year, 0)
+			for _, month := range year.Months {
+				month.Permalink = s.archivePermalink(orDefault(ac.MonthPermalink, "/:collection/archive/:year/:month"), collection, year.Year, month.Month)
+			}
+		}
+
+		add := func(title, permalink string, year *YearGroup, month *MonthGroup) {
+			route := &Route{Permalink: permalink, Template: template}
+			route.Data = s.PageData(s.generatedPage(title, "Content from "+title, permalink))
+			route.Data.Archive = &Archive{Collection: collection, Permalink: archive.Permalink, Years: archive.Years, Year: year, Month: month}
+			if err := s.addRoute(route); err != nil {
+				errs = append(errs, &FileError{Path: "config.json", Err: fmt.Errorf("%s: %w", name, err)})
+			}
+		}
+		add(name, archive.Permalink, nil, nil)
+		for _, year := range archive.Years {
+			add(fmt.Sprintf("%s %d", name, year.Year), year.Permalink, year, nil)
+			for _, month := range year.Months {
+				add(fmt.Sprintf("%s %s %d", name, strings.ToLower(month.Month.String()), year.Year), month.Permalink, year, month)
+			}
+		}
+	}
+	return errs
+}
+
+// archivePermalink expands an archive permalink pattern.
+func (s *Site) archivePermalink(pattern, collection string, year int, month time.Month) string {
+	r := strings.NewReplacer(":collection", collection, ":year", strconv.Itoa(year), ":month", fmt.Sprintf("%02d", int(month)))
+	return cleanPermalink(s.Config, r.Replace(pattern))
+}
@@ -0,0 +1,30 @@
+package site
+
+import (
+	"time"
+	"ts-www/build/internal/config"
+)
+
+// dateLayouts are the layouts formatDate knows by name besides those in the
+// config.
+var dateLayouts = map[string]string{
+	"date":     "2006-01-02",
+	"datetime": "2006-01-02 15:04",
+	"rfc3339":  time.RFC3339,
+	"long":     "January 2, 2006",
+}
+
+// FormatDate formats a time in the site's time zone with a layout named in
+// dates.layouts or dateLayouts, or otherwise with layout itself as a Go time
+// layout. The zero time formats as an empty string.
+func FormatDate(cfg *config.Config, layout string, t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	if named, ok := cfg.Dates.Layouts[layout]; ok {
+		layout = named
+	} else if named, ok := dateLayouts[layout]; ok {
+		layout = named
+	}
+	return t.In(cfg.Dates.Location()).Format(layout)
+}
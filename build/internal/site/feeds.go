@@ -0,0 +1,192 @@
+package site
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"ts-www/build/internal/config"
+	"ts-www/build/internal/feeds"
+	"ts-www/build/internal/models"
+	"ts-www/build/internal/search"
+)
+
+// feedFormat describes one syndication format and where its feeds live.
+type feedFormat struct {
+	name     string
+	file     string
+	mimeType string
+	write    func(io.Writer, feeds.Channel) error
+}
+
+var feedFormats = map[string]feedFormat{
+	"rss":      {name: "RSS", file: "rss.xml", mimeType: "application/rss+xml", write: feeds.WriteRSS},
+	"atom":     {name: "Atom", file: "atom.xml", mimeType: "application/atom+xml", write: feeds.WriteAtom},
+	"jsonfeed": {name: "JSON Feed", file: "feed.json", mimeType: "application/feed+json", write: feeds.WriteJSONFeed},
+}
+
+// buildFeeds registers a route for the site-wide feed and for the feed of
+// every configured collection, in each configured format, and records the
+// links that let pages advertise them.
+func (s *Site) buildFeeds() []error {
+	s.alternates = make(map[string][]Alternate)
+
+	var errs []error
+	for _, collection := range s.feedCollections() {
+		formats := s.feedFormatsFor(collection)
+		if len(formats) > 0 && s.Config.BaseURL == "" {
+			return append(errs, &FileError{Path: "config.json", Err: fmt.Errorf("feeds need a baseURL for their absolute links")})
+		}
+		for _, format := range formats {
+			ff, ok := feedFormats[format]
+			if !ok {
+				errs = append(errs, &FileError{Path: "config.json", Err: fmt.Errorf("unknown feed format %q", format)})
+				continue
+			}
+
+			permalink := path.Join("/", collection, ff.file)
+			title := s.Config.SiteTitle
+			if collection != "" {
+				title += " ~ " + collection
+			}
+			collection := collection
+			route := &Route{
+				Permalink: permalink,
+				Data:      PageData{Page: s.generatedPage(title, s.Config.SiteDescription, permalink)},
+				Render: func(w io.Writer) error {
+					return ff.write(w, s.feedChannel(title, collection, permalink))
+				},
+				Uses: []string{"Feed"},
+			}
+			if err := s.addRoute(route); err != nil {
+				errs = append(errs, &FileError{Path: "config.json", Err: fmt.Errorf("%s feed: %w", ff.name, err)})
+				continue
+			}
+			s.alternates[collection] = append(s.alternates[collection], Alternate{Type: ff.mimeType, Title: title + " (" + ff.name + ")", Href: permalink})
+		}
+	}
+	return errs
+}
+
+// feedCollections returns "" for the site-wide feed followed by every
+// collection that has feeds, either through feeds.collections or through
+// list formats in its outputs.
+func (s *Site) feedCollections() []string {
+	collections := append([]string{""}, s.Config.Feeds.Collections...)
+	var extra []string
+	for collection, outputs := range s.Config.Outputs {
+		if len(outputs.List) > 0 && !slices.Contains(collections, collection) {
+			extra = append(extra, collection)
+		}
+	}
+	sort.Strings(extra)
+	return append(collections, extra...)
+}
+
+// feedFormatsFor returns the feed formats of a collection: its own list
+// formats when its outputs name any, otherwise feeds.formats.
+func (s *Site) feedFormatsFor(collection string) []string {
+	if formats := s.Config.Outputs[collection].List; collection != "" && len(formats) > 0 {
+		return formats
+	}
+	return s.Config.Feeds.Formats
+}
+
+// feedChannel assembles the items of a feed from the content feed, optionally
+// limited to one collection.
+func (s *Site) feedChannel(title, collection, permalink string) feeds.Channel {
+	fc := s.Config.Feeds
+	channel := feeds.Channel{
+		Title:       title,
+		Description: s.Config.SiteDescription,
+		Link:        AbsURL(s.Config, URL(s.Config, "/"+collection)),
+		FeedURL:     AbsURL(s.Config, permalink),
+	}
+
+	for i := range s.Feed {
+		page := &s.Feed[i]
+		if collection != "" && page.Collection != collection {
+			continue
+		}
+		if fc.Limit > 0 && len(channel.Items) == fc.Limit {
+			break
+		}
+
+		item := feeds.Item{
+			ID:        AbsURL(s.Config, page.Permalink),
+			Title:     page.Title,
+			Link:      AbsURL(s.Config, page.URL),
+			Published: page.Date,
+			Updated:   page.Lastmod,
+			Summary:   page.Description,
+			Tags:      page.Tags,
+		}
+		if item.Summary == "" {
+			item.Summary = search.PlainText(string(page.Summary))
+		}
+		if fc.Content == "full" {
+			// Feed readers show the content away from its page, so its links
+			// can't be relative
+			base, _ := url.Parse(AbsURL(s.Config, page.Permalink))
+			item.Content = absoluteLinks(string(page.HTML), base)
+		}
+		for _, t := range []time.Time{item.Published, item.Updated} {
+			if t.After(channel.Updated) {
+				channel.Updated = t
+			}
+		}
+		channel.Items = append(channel.Items, item)
+	}
+	if channel.Updated.IsZero() {
+		channel.Updated = time.Unix(0, 0).UTC()
+	}
+	return channel
+}
+
+var linkAttrPattern = regexp.MustCompile(`\b(href|src)="([^"]*)"`)
+
+// absoluteLinks resolves the href and src attributes of rendered HTML against
+// base.
+func absoluteLinks(body string, base *url.URL) string {
+	if base == nil {
+		return body
+	}
+	return linkAttrPattern.ReplaceAllStringFunc(body, func(attr string) string {
+		match := linkAttrPattern.FindStringSubmatch(attr)
+		ref, err := url.Parse(html.UnescapeString(match[2]))
+		if err != nil {
+			return attr
+		}
+		return match[1] + `="` + html.EscapeString(base.ResolveReference(ref).String()) + `"`
+	})
+}
+
+// alternatesFor returns the feeds a page advertises: the site-wide ones plus
+// those of its collection. A page named after a collection, such as
+// page/writing.md, advertises that collection's feeds too.
+func (s *Site) alternatesFor(page *models.Content) []Alternate {
+	alternates := append([]Alternate(nil), s.alternates[""]...)
+	collection := page.Collection
+	if collection == "page" {
+		collection = page.Slug
+	}
+	if collection != "" {
+		alternates = append(alternates, s.alternates[collection]...)
+	}
+	return alternates
+}
+
+// AbsURL turns a site path into an absolute URL on the configured base URL.
+// Links that already carry a scheme are returned unchanged.
+func AbsURL(cfg *config.Config, link string) string {
+	if u, err := url.Parse(link); err == nil && u.IsAbs() {
+		return link
+	}
+	return strings.TrimSuffix(cfg.BaseURL, "/") + "/" + strings.TrimPrefix(link, "/")
+}
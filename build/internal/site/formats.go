@@ -0,0 +1,162 @@
+package site
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"ts-www/build/internal/models"
+)
+
+// pageFormat is a format content pages can be written in besides HTML, which
+// is always rendered by a template.
+type pageFormat struct {
+	name     string
+	ext      string
+	mimeType string
+	write    func(w io.Writer, s *Site, page *models.Content) error
+}
+
+var pageFormats = map[string]pageFormat{
+	"json": {name: "JSON", ext: ".json", mimeType: "application/json", write: writePageJSON},
+}
+
+// checkOutputs reports page formats in the config that don't exist.
+func (s *Site) checkOutputs() []error {
+	collections := make([]string, 0, len(s.Config.Outputs))
+	for collection := range s.Config.Outputs {
+		collections = append(collections, collection)
+	}
+	sort.Strings(collections)
+
+	var errs []error
+	for _, collection := range collections {
+		for _, format := range s.Config.Outputs[collection].Page {
+			if _, ok := pageFormats[format]; !ok && format != "html" {
+				errs = append(errs, &FileError{Path: "config.json", Err: fmt.Errorf("outputs %s: unknown page format %q", collection, format)})
+			}
+		}
+	}
+	return errs
+}
+
+// pageOutputs returns the formats the pages of a collection are written in,
+// HTML only unless its outputs say otherwise.
+func (s *Site) pageOutputs(collection string) []string {
+	if formats := s.Config.Outputs[collection].Page; len(formats) > 0 {
+		return formats
+	}
+	return []string{"html"}
+}
+
+// addPageRoutes registers a route for every format the page is written in.
+func (s *Site) addPageRoutes(page *models.Content, source string) error {
+	for _, format := range s.pageOutputs(page.Collection) {
+		if format == "html" {
+			route := &Route{Permalink: page.Permalink, Template: TemplateFor(page), Source: source, Data: PageData{Page: page}}
+			if err := s.addRoute(route); err != nil {
+				return err
+			}
+			continue
+		}
+
+		pf, ok := pageFormats[format]
+		if !ok {
+			continue // Reported once by checkOutputs
+		}
+		route := &Route{
+			Permalink: formatPermalink(page.Permalink, pf.ext),
+			Source:    source,
+			Data:      PageData{Page: page},
+			Render:    func(w io.Writer) error { return pf.write(w, s, page) },
+		}
+		if err := s.addRoute(route); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pageAlternates returns the links to the other formats of a content page.
+func (s *Site) pageAlternates(page *models.Content) []Alternate {
+	if page.File == "" {
+		return nil // Generated listings only come as HTML
+	}
+	var alternates []Alternate
+	for _, format := range s.pageOutputs(page.Collection) {
+		if pf, ok := pageFormats[format]; ok {
+			alternates = append(alternates, Alternate{Type: pf.mimeType, Title: page.Title + " (" + pf.name + ")", Href: formatPermalink(page.Permalink, pf.ext)})
+		}
+	}
+	return alternates
+}
+
+// formatPermalink returns the permalink of a page in a format other than
+// HTML: "/writing/intro" becomes "/writing/intro.json" and directories such
+// as "/" get an index file, "/index.json".
+func formatPermalink(permalink, ext string) string {
+	if strings.HasSuffix(permalink, "/") {
+		return permalink + "index" + ext
+	}
+	return permalink + ext
+}
+
+// pageDocument is the JSON form of a page. Its fields only ever grow, so
+// clients can rely on them across builds.
+type pageDocument struct {
+	Version     int                 `json:"version"`
+	Title       string              `json:"title"`
+	Description string              `json:"description,omitempty"`
+	Collection  string              `json:"collection"`
+	Slug        string              `json:"slug"`
+	Permalink   string              `json:"permalink"`
+	URL         string              `json:"url"`
+	Date        string              `json:"date,omitempty"`    // RFC 3339
+	Lastmod     string              `json:"lastmod,omitempty"` // RFC 3339
+	Terms       map[string][]string `json:"terms,omitempty"`
+	Featured    bool                `json:"featured,omitempty"`
+	Draft       bool                `json:"draft,omitempty"`
+	ContentHTML string              `json:"content_html"`
+	TOCHTML     string              `json:"toc_html,omitempty"`
+	SummaryHTML string              `json:"summary_html,omitempty"`
+	WordCount   int                 `json:"word_count"`
+	ReadingTime int                 `json:"reading_time"` // Minutes
+}
+
+// formatRFC3339 formats a date for JSON, leaving out the zero time.
+func formatRFC3339(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// writePageJSON writes a page's metadata and rendered body as JSON.
+func writePageJSON(w io.Writer, s *Site, page *models.Content) error {
+	doc := pageDocument{
+		Version:     1,
+		Title:       page.Title,
+		Description: page.Description,
+		Collection:  page.Collection,
+		Slug:        page.Slug,
+		Permalink:   page.Permalink,
+		URL:         AbsURL(s.Config, page.URL),
+		Date:        formatRFC3339(page.Date),
+		Lastmod:     formatRFC3339(page.Lastmod),
+		Terms:       page.Terms,
+		Featured:    page.Featured,
+		Draft:       page.Draft,
+		ContentHTML: string(page.HTML),
+		TOCHTML:     string(page.TOC),
+		SummaryHTML: string(page.Summary),
+		WordCount:   page.WordCount,
+		ReadingTime: page.ReadingTime,
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(doc)
+}
@@ -0,0 +1,44 @@
+package site
+
+import (
+	"html/template"
+	"time"
+	"ts-www/build/internal/config"
+	"ts-www/build/internal/markdown"
+	"ts-www/build/internal/utils"
+)
+
+// FuncMap returns the template functions that depend on the configuration or
+// on the site model.
+func FuncMap(cfg *config.Config) template.FuncMap {
+	md, _ := markdown.New(cfg.Markdown) // Unknown extensions are reported by Load
+	funcs := template.FuncMap{
+		"markDown": md.HTML,
+		"url":      func(link string) string { return URL(cfg, link) },
+		"absURL":   func(link string) string { return AbsURL(cfg, link) },
+		// URL of the stylesheet for highlighted code, empty when none is written
+		"syntaxCSS": func() string {
+			if md.HighlightCSS() == "" {
+				return ""
+			}
+			return URL(cfg, "/public/"+markdown.StyleSheet)
+		},
+		"parseDate": func(date string) time.Time {
+			t, _ := utils.ParseTime(date, cfg.Dates.Location())
+			return t
+		},
+		"formatDate": func(layout string, t time.Time) string { return FormatDate(cfg, layout, t) },
+		"groupByYear": func(list interface{}) ([]*YearGroup, error) {
+			pages, err := contentList(list)
+			return GroupByYear(pages), err
+		},
+		"groupByMonth": func(list interface{}) ([]*MonthGroup, error) {
+			pages, err := contentList(list)
+			return GroupByMonth(pages), err
+		},
+	}
+	for name, fn := range paramFuncs() {
+		funcs[name] = fn
+	}
+	return funcs
+}
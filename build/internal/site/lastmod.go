@@ -0,0 +1,54 @@
+package site
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// gitLastmod sets the lastmod of pages without one in their front matter to
+// the time of the last commit that changed their file. Files that were never
+// committed keep the zero time.
+func (s *Site) gitLastmod() error {
+	// One log of the content directory, newest first, with the files of each
+	// commit relative to it: "\x00<commit time>" lines followed by file names
+	cmd := exec.Command("git", "-c", "core.quotePath=false", "log", "--format=%x00%cI", "--name-only", "--relative", "--", ".")
+	cmd.Dir = s.Config.ContentPath
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			err = fmt.Errorf("%w: %s", err, msg)
+		}
+		return fmt.Errorf("dates.lastmod: git log: %w", err)
+	}
+
+	lastmod := make(map[string]time.Time)
+	var commit time.Time
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "\x00"):
+			if commit, err = time.Parse(time.RFC3339, line[1:]); err != nil {
+				return fmt.Errorf("dates.lastmod: git log: %w", err)
+			}
+		case line != "":
+			if _, ok := lastmod[line]; !ok {
+				lastmod[line] = commit
+			}
+		}
+	}
+
+	loc := s.Config.Dates.Location()
+	for _, page := range s.Pages {
+		if t, ok := lastmod[page.File]; ok && page.Lastmod.IsZero() {
+			page.Lastmod = t.In(loc)
+		}
+	}
+	return nil
+}
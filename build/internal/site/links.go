@@ -0,0 +1,251 @@
+package site
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"ts-www/build/internal/markdown"
+	"ts-www/build/internal/models"
+)
+
+// linkResolver finds the content internal links point at: [[target]] wiki
+// links and markdown links to ref:target.
+type linkResolver struct {
+	s        *Site
+	byPath   map[string]*models.Content   // By content path without extension, "writing/intro"
+	byName   map[string][]*models.Content // By slug and file name, "intro"
+	headings map[*models.Content][]markdown.Heading
+}
+
+func (s *Site) newLinkResolver() *linkResolver {
+	r := &linkResolver{
+		s:        s,
+		byPath:   make(map[string]*models.Content),
+		byName:   make(map[string][]*models.Content),
+		headings: make(map[*models.Content][]markdown.Heading),
+	}
+	for _, page := range s.Pages {
+		file := strings.TrimSuffix(page.File, path.Ext(page.File))
+		r.byPath[file] = page
+		names := []string{path.Base(file)}
+		if page.Slug != names[0] {
+			names = append(names, page.Slug)
+		}
+		for _, name := range names {
+			r.byName[name] = append(r.byName[name], page)
+		}
+	}
+	return r
+}
+
+// Ref returns the content a link target without an anchor names.
+func (r *linkResolver) Ref(target string) (*models.Content, error) {
+	target = strings.TrimSuffix(strings.TrimPrefix(target, "/"), ".md")
+	if page, ok := r.byPath[target]; ok {
+		return page, nil
+	}
+	switch pages := r.byName[target]; len(pages) {
+	case 0:
+		return nil, fmt.Errorf("no content at %s", target)
+	case 1:
+		return pages[0], nil
+	default:
+		files := make([]string, len(pages))
+		for i, page := range pages {
+			files[i] = page.File
+		}
+		sort.Strings(files)
+		return nil, fmt.Errorf("%s is ambiguous, it could be any of %s", target, strings.Join(files, ", "))
+	}
+}
+
+// resolve returns the URL and title of a link target. Links go to the page
+// the target is rendered as, even when its front matter url points elsewhere.
+// A target of only an anchor points into the linking page itself, and a link
+// to an anchor takes the heading's text as its title.
+func (r *linkResolver) resolve(from *models.Content, target string) (url, title string, err error) {
+	name, anchor, hasAnchor := strings.Cut(strings.TrimSpace(target), "#")
+	page := from
+	if name != "" {
+		if page, err = r.Ref(name); err != nil {
+			return "", "", err
+		}
+	}
+	if !hasAnchor {
+		return page.Permalink, page.Title, nil
+	}
+
+	heading, err := r.heading(page, anchor)
+	if err != nil {
+		return "", "", err
+	}
+	url = "#" + heading.ID
+	if page != from {
+		url = page.Permalink + url
+	}
+	return url, heading.Text, nil
+}
+
+// heading finds a heading of a page by its ID or, ignoring case, its text.
+func (r *linkResolver) heading(page *models.Content, anchor string) (markdown.Heading, error) {
+	headings, ok := r.headings[page]
+	if !ok {
+		headings = r.s.Markdown.Headings(page.Body)
+		r.headings[page] = headings
+	}
+	for _, h := range headings {
+		if h.ID == anchor {
+			return h, nil
+		}
+	}
+	for _, h := range headings {
+		if strings.EqualFold(h.Text, anchor) {
+			return h, nil
+		}
+	}
+	return markdown.Heading{}, fmt.Errorf("%s has no heading %q", page.File, anchor)
+}
+
+// rewrite replaces the internal links in the body of a page with markdown
+// links to their targets. Links in code are left alone. Unresolved links are
+// reported with their line and, unless they fail the build, rendered as
+// their text.
+func (r *linkResolver) rewrite(page *models.Content) (body []byte, errs []error, warnings []*FileError) {
+	src := string(page.Body)
+	if !strings.Contains(src, "[[") && !strings.Contains(src, "](ref:") {
+		return page.Body, nil, nil
+	}
+
+	var b strings.Builder
+	fence := ""
+	for line, text := range strings.SplitAfter(src, "\n") {
+		trimmed := strings.TrimLeft(text, " ")
+		switch {
+		case fence != "":
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			b.WriteString(text)
+			continue
+		case strings.HasPrefix(trimmed, "```"), strings.HasPrefix(trimmed, "~~~"):
+			fence = trimmed[:3]
+			b.WriteString(text)
+			continue
+		}
+
+		b.WriteString(r.rewriteLine(page, text, func(err error) {
+			fileErr := &FileError{Path: r.s.SourcePath(page), Line: page.BodyLine + line, Err: err}
+			if r.s.Config.Links.Unresolved == "warn" {
+				warnings = append(warnings, fileErr)
+			} else {
+				errs = append(errs, fileErr)
+			}
+		}))
+	}
+	return []byte(b.String()), errs, warnings
+}
+
+// rewriteLine rewrites the links on one line outside code spans.
+func (r *linkResolver) rewriteLine(page *models.Content, line string, fail func(error)) string {
+	var b strings.Builder
+	for i := 0; i < len(line); {
+		switch {
+		case line[i] == '`':
+			// Copy code spans verbatim, up to the closing run of backticks
+			run := len(line[i:]) - len(strings.TrimLeft(line[i:], "`"))
+			end := strings.Index(line[i+run:], line[i:i+run])
+			if end < 0 {
+				b.WriteString(line[i:])
+				return b.String()
+			}
+			end += i + run + run
+			b.WriteString(line[i:end])
+			i = end
+
+		case strings.HasPrefix(line[i:], "[["):
+			end := strings.Index(line[i+2:], "]]")
+			if end < 0 {
+				b.WriteString(line[i:])
+				return b.String()
+			}
+			// The text after a | is markdown; a title is used as it is
+			target, text, _ := strings.Cut(line[i+2:i+2+end], "|")
+			i += 2 + end + 2
+			url, title, err := r.resolve(page, target)
+			if text == "" {
+				text = escapeLinkText(title)
+			}
+			if err != nil {
+				fail(fmt.Errorf("link [[%s]]: %w", target, err))
+				if text == "" {
+					text = escapeLinkText(target)
+				}
+				b.WriteString(text)
+				continue
+			}
+			fmt.Fprintf(&b, "[%s](%s)", text, url)
+
+		case line[i] == '[':
+			text, target, end := refLink(line[i:])
+			if end == 0 {
+				b.WriteByte('[')
+				i++
+				continue
+			}
+			i += end
+			url, title, err := r.resolve(page, target)
+			if text == "" {
+				text = escapeLinkText(title)
+			}
+			if err != nil {
+				fail(fmt.Errorf("link to ref:%s: %w", target, err))
+				if text == "" {
+					text = escapeLinkText(target)
+				}
+				b.WriteString(text)
+				continue
+			}
+			fmt.Fprintf(&b, "[%s](%s)", text, url)
+
+		default:
+			b.WriteByte(line[i])
+			i++
+		}
+	}
+	return b.String()
+}
+
+// refLink parses a markdown link to ref:target at the start of s, returning
+// its text as written, its target and its length, or a length of 0 when s
+// doesn't start with one.
+func refLink(s string) (text, target string, length int) {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth > 0 {
+				continue
+			}
+			rest := s[i+1:]
+			if !strings.HasPrefix(rest, "(ref:") {
+				return "", "", 0
+			}
+			end := strings.IndexByte(rest, ')')
+			if end < 0 {
+				return "", "", 0
+			}
+			return s[1:i], rest[len("(ref:"):end], i + 1 + end + 1
+		}
+	}
+	return "", "", 0
+}
+
+// escapeLinkText escapes the characters markdown would read as formatting in
+// the text of a link.
+var escapeLinkText = strings.NewReplacer(`\`, `\\`, `[`, `\[`, `]`, `\]`, `*`, `\*`, `_`, `\_`, "`", "\\`").Replace
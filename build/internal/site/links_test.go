@@ -0,0 +1,144 @@
+package site
+
+import (
+	"strings"
+	"testing"
+	"ts-www/build/internal/config"
+	"ts-www/build/internal/markdown"
+	"ts-www/build/internal/models"
+)
+
+func testLinkSite(t *testing.T, unresolved string) *Site {
+	t.Helper()
+	md, err := markdown.New(config.MarkdownConfig{Extensions: []string{"fencedCode", "headingIDs"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := func(file, slug, title, permalink, body string) *models.Content {
+		return &models.Content{File: file, Slug: slug, Title: title, Permalink: permalink, URL: permalink, Body: []byte(body), BodyLine: 4}
+	}
+	external := page("projects/ext.md", "ext", "External", "/projects/ext", "## Setup\n")
+	external.URL = "https://example.com/x"
+	return &Site{
+		Config:   &config.Config{ContentPath: "content", Links: config.LinksConfig{Unresolved: unresolved}},
+		Markdown: md,
+		Pages: []*models.Content{
+			page("writing/intro.md", "intro", "Intro", "/writing/intro", "# Intro\n"),
+			page("writing/go-notes.md", "go", "Go *notes*", "/writing/go", ""),
+			page("projects/intro.md", "intro", "Project intro", "/projects/intro", ""),
+			page("page/about.md", "about", "About", "/about", "## Contact me {#contact}\n"),
+			external,
+		},
+	}
+}
+
+func TestLinkRewrite(t *testing.T) {
+	s := testLinkSite(t, "")
+	links := s.newLinkResolver()
+	from := s.Pages[0]
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"wiki link by path", "See [[writing/go-notes]].", `See [Go \*notes\*](/writing/go).`},
+		{"wiki link by slug", "[[go]] and [[about]]", `[Go \*notes\*](/writing/go) and [About](/about)`},
+		{"wiki link with text", "[[about|**me**]]", "[**me**](/about)"},
+		{"wiki link with extension and slash", "[[/page/about.md]]", "[About](/about)"},
+		{"wiki link to a heading", "[[about#contact]]", "[Contact me](/about#contact)"},
+		{"wiki link to a heading by text", "[[about#Contact Me]]", "[Contact me](/about#contact)"},
+		{"wiki link to a heading of the page", "## Getting started\n[[#getting-started]]", "## Getting started\n[Getting started](#getting-started)"},
+		{"wiki link to a project with an external url", "[[ext]]", "[External](/projects/ext)"},
+		{"wiki link to a heading of a project with an external url", "[[ext#setup]]", "[Setup](/projects/ext#setup)"},
+		{"ref link to a project with an external url", "[site](ref:projects/ext)", "[site](/projects/ext)"},
+		{"ref link", "[the notes](ref:writing/go-notes)", "[the notes](/writing/go)"},
+		{"ref link without text", "[](ref:about#contact)", "[Contact me](/about#contact)"},
+		{"ref link with brackets in its text", `[a [b] \]](ref:about)`, `[a [b] \]](/about)`},
+		{"ordinary links", "[x](https://example.com) [y] [[", "[x](https://example.com) [y] [["},
+		{"code span", "`[[about]]` and ``[[go]]`` [[about]]", "`[[about]]` and ``[[go]]`` [About](/about)"},
+		{"fenced code", "```\n[[about]]\n```\n[[about]]\n~~~\n[[go]]\n~~~\n", "```\n[[about]]\n```\n[About](/about)\n~~~\n[[go]]\n~~~\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := *from
+			page.Body = []byte(tt.body)
+			body, errs, warnings := links.rewrite(&page)
+			if len(errs) > 0 || len(warnings) > 0 {
+				t.Fatalf("errors %v, warnings %v", errs, warnings)
+			}
+			if string(body) != tt.want {
+				t.Errorf("rewrite(%q) = %q, want %q", tt.body, body, tt.want)
+			}
+		})
+	}
+}
+
+func TestLinkRewriteUnresolved(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    string
+		line    int
+		message string
+	}{
+		{"missing page", "text\n[[nowhere]]", "text\nnowhere", 5, "no content at nowhere"},
+		{"missing page with text", "[[nowhere|the text]]", "the text", 4, "no content at nowhere"},
+		{"ambiguous name", "[[intro]]", "intro", 4, "projects/intro.md, writing/intro.md"},
+		{"missing heading", "\n\n[link](ref:about#nope)", "\n\nlink", 6, `has no heading "nope"`},
+	}
+	for _, unresolved := range []string{"error", "warn"} {
+		s := testLinkSite(t, unresolved)
+		links := s.newLinkResolver()
+		for _, tt := range tests {
+			t.Run(unresolved+" "+tt.name, func(t *testing.T) {
+				page := *s.Pages[3]
+				page.Body = []byte(tt.body)
+				body, errs, warnings := links.rewrite(&page)
+				if string(body) != tt.want {
+					t.Errorf("rewrite(%q) = %q, want %q", tt.body, body, tt.want)
+				}
+				problems := errs
+				if unresolved == "warn" {
+					if len(errs) > 0 {
+						t.Errorf("errors %v, want warnings only", errs)
+					}
+					problems = nil
+					for _, w := range warnings {
+						problems = append(problems, w)
+					}
+				} else if len(warnings) > 0 {
+					t.Errorf("warnings %v, want errors only", warnings)
+				}
+				if len(problems) != 1 {
+					t.Fatalf("problems %v, want one", problems)
+				}
+				fileErr := problems[0].(*FileError)
+				if fileErr.Path != "content/page/about.md" || fileErr.Line != tt.line || !strings.Contains(fileErr.Err.Error(), tt.message) {
+					t.Errorf("problem %v, want content/page/about.md:%d mentioning %q", fileErr, tt.line, tt.message)
+				}
+			})
+		}
+	}
+}
+
+func TestRefLink(t *testing.T) {
+	tests := []struct {
+		s      string
+		text   string
+		target string
+		length int
+	}{
+		{"[a](ref:b) rest", "a", "b", 10},
+		{"[a [b]](ref:c#d)", "a [b]", "c#d", 16},
+		{`[a \]](ref:b)`, `a \]`, "b", 13},
+		{"[a](https://b)", "", "", 0},
+		{"[a](ref:b", "", "", 0},
+		{"[a", "", "", 0},
+	}
+	for _, tt := range tests {
+		text, target, length := refLink(tt.s)
+		if text != tt.text || target != tt.target || length != tt.length {
+			t.Errorf("refLink(%q) = %q, %q, %d, want %q, %q, %d", tt.s, text, target, length, tt.text, tt.target, tt.length)
+		}
+	}
+}
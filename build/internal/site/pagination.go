@@ -0,0 +1,117 @@
+package site
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"ts-www/build/internal/models"
+	"ts-www/build/internal/utils"
+)
+
+// Paginator is one page of a paginated collection list.
+type Paginator struct {
+	Items      []*models.Content // Content on this page, newest first
+	Current    int               // Number of this page, starting at 1
+	Total      int               // Number of pages
+	TotalItems int
+	PageSize   int
+	FirstURL   string
+	LastURL    string
+	PrevURL    string // Empty on the first page
+	NextURL    string // Empty on the last page
+}
+
+// buildPagination splits the content of every paginated collection into pages.
+// The first page is the collection's list page, content/page/<collection>.md,
+// which gets the paginator when its data is filled in; further pages render
+// that same page again at their own permalink. Collections without a list
+// page get a generated one.
+func (s *Site) buildPagination() []error {
+	s.paginators = make(map[*models.Content]*Paginator)
+
+	collections := make([]string, 0, len(s.Config.Pagination))
+	for collection := range s.Config.Pagination {
+		collections = append(collections, collection)
+	}
+	sort.Strings(collections)
+
+	var errs []error
+	for _, collection := range collections {
+		pc := s.Config.Pagination[collection]
+		if pc.PageSize <= 0 {
+			errs = append(errs, &FileError{Path: "config.json", Err: fmt.Errorf("pagination %s: pageSize must be positive", collection)})
+			continue
+		}
+
+		var items []*models.Content
+		for _, page := range s.Pages {
+			if page.Collection == collection {
+				items = append(items, page)
+			}
+		}
+		sortByDate(items)
+
+		listPage, generated := s.listPage(collection), false
+		if listPage == nil {
+			listPage, generated = s.generatedPage(collection, "", URL(s.Config, "/"+collection)), true
+		}
+		template := orDefault(pc.Template, "page")
+		if generated && utils.Templates.Lookup(template) == nil {
+			errs = append(errs, &FileError{Path: "config.json", Err: fmt.Errorf("pagination %s: template %s not found", collection, template)})
+			continue
+		}
+
+		total := max(1, (len(items)+pc.PageSize-1)/pc.PageSize)
+		urls := make([]string, total)
+		urls[0] = listPage.Permalink
+		for n := 2; n <= total; n++ {
+			pattern := orDefault(pc.Permalink, "/:collection/page/:num")
+			urls[n-1] = cleanPermalink(s.Config, strings.NewReplacer(":collection", collection, ":num", strconv.Itoa(n)).Replace(pattern))
+		}
+
+		for n := 1; n <= total; n++ {
+			p := &Paginator{
+				Items:      items[(n-1)*pc.PageSize : min(n*pc.PageSize, len(items))],
+				Current:    n,
+				Total:      total,
+				TotalItems: len(items),
+				PageSize:   pc.PageSize,
+				FirstURL:   urls[0],
+				LastURL:    urls[total-1],
+			}
+			if n > 1 {
+				p.PrevURL = urls[n-2]
+			}
+			if n < total {
+				p.NextURL = urls[n]
+			}
+
+			if n == 1 && !generated {
+				s.paginators[listPage] = p
+				continue
+			}
+			route := &Route{Permalink: urls[n-1], Template: template}
+			if !generated {
+				route.Template = TemplateFor(listPage)
+			}
+			route.Data = s.PageData(listPage)
+			route.Data.Paginator = p
+			if err := s.addRoute(route); err != nil {
+				errs = append(errs, &FileError{Path: "config.json", Err: fmt.Errorf("pagination %s: %w", collection, err)})
+			}
+		}
+	}
+	return errs
+}
+
+// listPage returns the page listing a collection, content/page/<collection>.md,
+// or nil if there is none.
+func (s *Site) listPage(collection string) *models.Content {
+	for _, page := range s.Pages {
+		if page.Collection == "page" && page.Slug == collection {
+			return page
+		}
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package site
+
+import (
+	"fmt"
+	"strings"
+)
+
+// lookupParam finds a front matter parameter by key. Dotted keys such as
+// "links.source" look into nested maps.
+func lookupParam(params map[string]interface{}, key string) (interface{}, bool) {
+	if value, ok := params[key]; ok {
+		return value, true
+	}
+	first, rest, nested := strings.Cut(key, ".")
+	if !nested {
+		return nil, false
+	}
+	inner, ok := params[first].(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	return lookupParam(inner, rest)
+}
+
+// paramFuncs returns the template functions that read .Page.Params. Missing
+// parameters are the zero value of their type so templates can fall back with
+// "or"; parameters of another type fail the template.
+func paramFuncs() map[string]interface{} {
+	return map[string]interface{}{
+		"hasParam": func(params map[string]interface{}, key string) bool {
+			_, ok := lookupParam(params, key)
+			return ok
+		},
+		"param": func(params map[string]interface{}, key string) interface{} {
+			value, _ := lookupParam(params, key)
+			return value
+		},
+		"paramString":  paramString,
+		"paramInt":     paramInt,
+		"paramFloat":   paramFloat,
+		"paramBool":    paramBool,
+		"paramStrings": paramStrings,
+	}
+}
+
+func paramString(params map[string]interface{}, key string) (string, error) {
+	value, ok := lookupParam(params, key)
+	if !ok {
+		return "", nil
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	return "", paramTypeError(key, value, "a string")
+}
+
+func paramInt(params map[string]interface{}, key string) (int, error) {
+	value, ok := lookupParam(params, key)
+	if !ok {
+		return 0, nil
+	}
+	switch v := value.(type) {
+	case int:
+		return v, nil
+	case float64:
+		if v == float64(int(v)) {
+			return int(v), nil
+		}
+	}
+	return 0, paramTypeError(key, value, "a whole number")
+}
+
+func paramFloat(params map[string]interface{}, key string) (float64, error) {
+	value, ok := lookupParam(params, key)
+	if !ok {
+		return 0, nil
+	}
+	switch v := value.(type) {
+	case int:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	}
+	return 0, paramTypeError(key, value, "a number")
+}
+
+func paramBool(params map[string]interface{}, key string) (bool, error) {
+	value, ok := lookupParam(params, key)
+	if !ok {
+		return false, nil
+	}
+	if b, ok := value.(bool); ok {
+		return b, nil
+	}
+	return false, paramTypeError(key, value, "true or false")
+}
+
+// paramStrings reads a list of strings. A single string is a list of one, the
+// way taxonomy terms can be written.
+func paramStrings(params map[string]interface{}, key string) ([]string, error) {
+	value, ok := lookupParam(params, key)
+	if !ok {
+		return nil, nil
+	}
+	switch v := value.(type) {
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		list := make([]string, len(v))
+		for i, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, paramTypeError(fmt.Sprintf("%s[%d]", key, i), item, "a string")
+			}
+			list[i] = s
+		}
+		return list, nil
+	}
+	return nil, paramTypeError(key, value, "a list of strings")
+}
+
+func paramTypeError(key string, value interface{}, want string) error {
+	return fmt.Errorf("param %s is %T, not %s", key, value, want)
+}
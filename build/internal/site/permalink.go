@@ -0,0 +1,145 @@
+package site
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"ts-www/build/internal/config"
+	"ts-www/build/internal/models"
+)
+
+// Default permalink patterns, used for collections that config.json doesn't
+// configure. Pages live at the root of the site.
+const (
+	defaultPermalink     = "/:collection/:slug"
+	defaultPagePermalink = "/:slug"
+)
+
+var permalinkToken = regexp.MustCompile(`:[a-z]+`)
+
+// Permalink expands the permalink pattern of the page's collection. Patterns
+// are made of literal segments and the tokens :collection, :slug, :filename,
+// :year, :month and :day. A pattern ending in a slash, or one whose last
+// segment expands to "index", addresses a directory. The trailing slash
+// policy in cfg.URLs has the final say over the form of the result.
+func Permalink(cfg *config.Config, page *models.Content) (string, error) {
+	pattern, ok := cfg.Permalinks[page.Collection]
+	if !ok {
+		pattern = defaultPermalink
+		if page.Collection == "page" {
+			pattern = defaultPagePermalink
+		}
+	}
+
+	var expandErr error
+	date := page.Date
+	expanded := permalinkToken.ReplaceAllStringFunc(pattern, func(token string) string {
+		switch token {
+		case ":collection":
+			return page.Collection
+		case ":slug":
+			return page.Slug
+		case ":filename":
+			return strings.TrimSuffix(path.Base(page.File), path.Ext(page.File))
+		case ":year", ":month", ":day":
+			if date.IsZero() {
+				expandErr = fmt.Errorf("permalink %q needs a date", pattern)
+				return ""
+			}
+			switch token {
+			case ":year":
+				return date.Format("2006")
+			case ":month":
+				return date.Format("01")
+			default:
+				return date.Format("02")
+			}
+		}
+		expandErr = fmt.Errorf("unknown token %s in permalink %q", token, pattern)
+		return ""
+	})
+	if expandErr != nil {
+		return "", expandErr
+	}
+
+	return cleanPermalink(cfg, expanded), nil
+}
+
+// cleanPermalink turns an expanded pattern into a canonical site path.
+func cleanPermalink(cfg *config.Config, expanded string) string {
+	trailingSlash := strings.HasSuffix(expanded, "/")
+	expanded = path.Clean("/" + expanded)
+	if path.Base(expanded) == "index" {
+		expanded, trailingSlash = path.Dir(expanded), true
+	}
+	return applyTrailingSlash(cfg.URLs, expanded, trailingSlash)
+}
+
+// applyTrailingSlash gives a cleaned site path the trailing slash required by
+// the URL policy, or the one asked for when the policy leaves it open.
+func applyTrailingSlash(urls config.URLConfig, p string, trailingSlash bool) string {
+	switch urls.TrailingSlash {
+	case "always":
+		trailingSlash = true
+	case "never":
+		trailingSlash = false
+	}
+	if trailingSlash && p != "/" {
+		p += "/"
+	}
+	return p
+}
+
+// OutputPath returns where a route is written relative to the output
+// directory. Routes that aren't HTML are written to their permalink as is.
+// For pages the pretty layout gives every page its own directory with an
+// index.html; otherwise directories get an index.html and anything else an
+// .html file.
+func (r *Route) OutputPath(urls config.URLConfig) string {
+	p := strings.TrimPrefix(r.Permalink, "/")
+	switch {
+	case r.Render != nil:
+	case p == "" || strings.HasSuffix(p, "/"):
+		p += "index.html"
+	case urls.Pretty:
+		p += "/index.html"
+	default:
+		p += ".html"
+	}
+	return filepath.FromSlash(p)
+}
+
+// URL applies the site's trailing slash policy to a site path written by
+// hand, such as a navigation link, so it matches the generated permalinks.
+// Paths to files, external URLs and fragments are returned unchanged.
+func URL(cfg *config.Config, link string) string {
+	if !strings.HasPrefix(link, "/") || strings.HasPrefix(link, "//") {
+		return link
+	}
+	rest := ""
+	if i := strings.IndexAny(link, "?#"); i >= 0 {
+		link, rest = link[:i], link[i:]
+	}
+	if path.Ext(link) != "" {
+		return link + rest
+	}
+	return applyTrailingSlash(cfg.URLs, path.Clean(link), strings.HasSuffix(link, "/")) + rest
+}
+
+// routeKey normalises a URL path so that "/about", "/about/" and
+// "/about.html" all address the same page.
+func routeKey(urlPath string) string {
+	urlPath = strings.TrimSuffix(urlPath, ".html")
+	urlPath = path.Clean("/" + urlPath)
+	if path.Base(urlPath) == "index" {
+		urlPath = path.Dir(urlPath)
+	}
+	return urlPath
+}
+
+// RouteByURL returns the route served at urlPath, or nil if there is none.
+func (s *Site) RouteByURL(urlPath string) *Route {
+	return s.routes[routeKey(urlPath)]
+}
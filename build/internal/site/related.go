@@ -0,0 +1,150 @@
+package site
+
+import (
+	"math"
+	"sort"
+	"ts-www/build/internal/models"
+	"ts-www/build/internal/search"
+	"ts-www/build/internal/utils"
+)
+
+// buildRelated picks the related content of every page outside the page
+// collection, scoring the other such pages by shared taxonomy terms, shared
+// collection and text similarity as weighted in the config.
+func (s *Site) buildRelated() {
+	rc := s.Config.Related
+	if rc.Count <= 0 {
+		return
+	}
+
+	var candidates []*models.Content
+	for _, page := range s.Pages {
+		if page.Collection != "page" {
+			candidates = append(candidates, page)
+		}
+	}
+
+	taxonomies := make([]string, 0, len(s.Config.Taxonomies))
+	for name := range s.Config.Taxonomies {
+		taxonomies = append(taxonomies, name)
+	}
+	sort.Strings(taxonomies) // Summed in a fixed order so scores are repeatable
+
+	var vectors []map[string]float64
+	if rc.Text != 0 {
+		vectors = textVectors(candidates)
+	}
+
+	type scored struct {
+		page  *models.Content
+		score float64
+	}
+	for i, page := range candidates {
+		var related []scored
+		for j, other := range candidates {
+			if i == j {
+				continue
+			}
+			score := 0.0
+			for _, taxonomy := range taxonomies {
+				weight, ok := rc.Taxonomies[taxonomy]
+				if !ok {
+					weight = 1
+				}
+				score += weight * float64(sharedTerms(page.Terms[taxonomy], other.Terms[taxonomy]))
+			}
+			if page.Collection == other.Collection {
+				score += rc.Collection
+			}
+			if vectors != nil {
+				score += rc.Text * cosine(vectors[i], vectors[j])
+			}
+			if score > 0 && score >= rc.Threshold {
+				related = append(related, scored{other, score})
+			}
+		}
+
+		// Ties go to the newer item, then to the title so builds are repeatable
+		sort.SliceStable(related, func(a, b int) bool {
+			if related[a].score != related[b].score {
+				return related[a].score > related[b].score
+			}
+			da, db := related[a].page.Date, related[b].page.Date
+			if !da.Equal(db) {
+				return da.After(db)
+			}
+			return related[a].page.Title < related[b].page.Title
+		})
+		page.Related = nil
+		for _, r := range related[:min(rc.Count, len(related))] {
+			page.Related = append(page.Related, r.page)
+		}
+	}
+}
+
+// sharedTerms counts the terms two pages have in common in one taxonomy,
+// comparing them by slug like the taxonomy pages do.
+func sharedTerms(a, b []string) int {
+	n := 0
+	for _, x := range a {
+		for _, y := range b {
+			if utils.Urlize(x) == utils.Urlize(y) {
+				n++
+				break
+			}
+		}
+	}
+	return n
+}
+
+// textVectors returns the TF-IDF vector of each page's rendered body, scaled
+// to unit length so that similarity is a plain dot product.
+func textVectors(pages []*models.Content) []map[string]float64 {
+	counts := make([]map[string]int, len(pages))
+	df := make(map[string]int)
+	for i, page := range pages {
+		counts[i] = make(map[string]int)
+		for _, token := range search.Tokenize(search.PlainText(string(page.HTML))) {
+			if counts[i][token] == 0 {
+				df[token]++
+			}
+			counts[i][token]++
+		}
+	}
+
+	vectors := make([]map[string]float64, len(pages))
+	for i := range pages {
+		vector := make(map[string]float64, len(counts[i]))
+		norm := 0.0
+		for token, count := range counts[i] {
+			weight := float64(count) * math.Log(float64(len(pages))/float64(df[token]))
+			if weight > 0 {
+				vector[token] = weight
+				norm += weight * weight
+			}
+		}
+		for token := range vector {
+			vector[token] /= math.Sqrt(norm)
+		}
+		vectors[i] = vector
+	}
+	return vectors
+}
+
+// cosine returns the similarity of two unit vectors. Tokens are visited in
+// order so that equal inputs always sum to exactly the same score.
+func cosine(a, b map[string]float64) float64 {
+	if len(b) < len(a) {
+		a, b = b, a
+	}
+	tokens := make([]string, 0, len(a))
+	for token := range a {
+		tokens = append(tokens, token)
+	}
+	sort.Strings(tokens)
+	dot := 0.0
+	for _, token := range tokens {
+		dot += a[token] * b[token]
+	}
+	return dot
+}
@@ -0,0 +1,79 @@
+package site
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"ts-www/build/internal/config"
+	"ts-www/build/internal/models"
+	"ts-www/build/internal/schema"
+	"ts-www/build/internal/utils"
+)
+
+// checkSchema validates the front matter of a loaded content file against
+// the schema of its collection, pointing each violation at the line of its
+// key. Collections without a schema accept anything.
+func checkSchema(cfg *config.Config, path string, page *models.Content) []*FileError {
+	s, ok := cfg.Schemas[page.Collection]
+	if !ok {
+		return nil
+	}
+	var errs []*FileError
+	for _, v := range schema.Validate(s, page.FrontMatter) {
+		errs = append(errs, &FileError{Path: path, Line: page.KeyLines[v.Key], Err: fmt.Errorf("schema of %s: %w", page.Collection, v)})
+	}
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Line < errs[j].Line })
+	return errs
+}
+
+// checkDates reports the front matter dates of a loaded content file that
+// didn't parse, except those its schema errors already reject.
+func checkDates(path string, page *models.Content, schemaErrs []*FileError) []*FileError {
+	rejected := make(map[string]bool)
+	for _, err := range schemaErrs {
+		var v *schema.Violation
+		if errors.As(err, &v) {
+			rejected[v.Key] = true
+		}
+	}
+	var errs []*FileError
+	for _, err := range page.DateErrors {
+		var dateErr *utils.DateError
+		if errors.As(err, &dateErr) && rejected[dateErr.Key] {
+			continue
+		}
+		fileErr := &FileError{Path: path, Err: err}
+		if dateErr != nil {
+			fileErr.Line = page.KeyLines[dateErr.Key]
+		}
+		errs = append(errs, fileErr)
+	}
+	return errs
+}
+
+// Check validates the front matter of every content file, drafts and
+// scheduled content included, without loading the rest of the site. Dates
+// that don't parse are problems here, though Load only warns about them. It
+// returns the number of files checked and their problems, in file order.
+func Check(cfg *config.Config) (int, []*FileError, error) {
+	paths, err := contentPaths(cfg)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	checked := 0
+	var problems []*FileError
+	for _, path := range paths {
+		page, err := utils.LoadPage(path, cfg)
+		if err != nil {
+			problems = append(problems, loadError(path, err))
+			continue
+		}
+		checked++
+		fileProblems := checkSchema(cfg, path, page)
+		fileProblems = append(fileProblems, checkDates(path, page, fileProblems)...)
+		sort.SliceStable(fileProblems, func(i, j int) bool { return fileProblems[i].Line < fileProblems[j].Line })
+		problems = append(problems, fileProblems...)
+	}
+	return checked, problems, nil
+}
@@ -0,0 +1,56 @@
+package site
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"ts-www/build/internal/config"
+)
+
+func TestCheckReportsDates(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"page/bad.md":       "---\ntitle: Bad\ndate: \"YYYY-MM-DD\"\n---\nbody\n",
+		"page/good.md":      "---\ntitle: Good\ndate: 2024-01-02\n---\nbody\n",
+		"writing/schema.md": "---\ntitle: Schema\ndate: someday\n---\nbody\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := &config.Config{
+		ContentPath: dir,
+		Schemas:     map[string]config.Schema{"writing": {Fields: map[string]config.FieldSchema{"date": {Type: "date"}}}},
+	}
+
+	checked, problems, err := Check(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checked != 3 {
+		t.Errorf("checked %d files, want 3", checked)
+	}
+	// The date the schema rejects is reported once, by the schema
+	want := []struct {
+		file    string
+		message string
+	}{
+		{"page/bad.md", "date"},
+		{"writing/schema.md", "schema of writing"},
+	}
+	if len(problems) != len(want) {
+		t.Fatalf("problems %v, want %d", problems, len(want))
+	}
+	for i, w := range want {
+		p := problems[i]
+		if p.Path != filepath.Join(dir, filepath.FromSlash(w.file)) || p.Line != 3 || !strings.Contains(p.Error(), w.message) {
+			t.Errorf("problem %v, want %s:3 mentioning %q", p, w.file, w.message)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package site
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"ts-www/build/internal/search"
+)
+
+// DefaultSearchPermalink is where the search index is written unless the
+// config says otherwise.
+const DefaultSearchPermalink = "/search.json"
+
+// buildSearch registers the search index when the config enables it. Content
+// that is only rendered as a preview and unlisted pages are left out.
+func (s *Site) buildSearch() []error {
+	sc := s.Config.Search
+	if !sc.Enabled {
+		return nil
+	}
+
+	ix := &search.Index{Version: search.Version, Documents: []search.Document{}}
+	for _, page := range s.Pages {
+		if !page.Published() || page.Unlisted {
+			continue
+		}
+		if len(sc.Collections) > 0 && !slices.Contains(sc.Collections, page.Collection) {
+			continue
+		}
+		ix.Documents = append(ix.Documents, search.NewDocument(page.Title, page.Description, page.Collection, page.Permalink, string(page.HTML)))
+	}
+
+	permalink := orDefault(sc.Permalink, DefaultSearchPermalink)
+	route := &Route{
+		Permalink: permalink,
+		Data:      PageData{Page: s.generatedPage("Search index", "", permalink)},
+		Render:    func(w io.Writer) error { return ix.Write(w) },
+		Inputs:    ix,
+	}
+	if err := s.addRoute(route); err != nil {
+		return []error{&FileError{Path: "config.json", Err: fmt.Errorf("search index: %w", err)}}
+	}
+	return nil
+}
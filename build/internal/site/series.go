@@ -0,0 +1,107 @@
+package site
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"ts-www/build/internal/models"
+	"ts-www/build/internal/utils"
+)
+
+// buildSeries gathers the parts of every series named in the front matter,
+// links each part to its siblings and registers an overview page per series.
+// Parts are ordered by their seriesOrder; parts without one follow the
+// numbered ones by date.
+func (s *Site) buildSeries() []error {
+	bySlug := make(map[string][]*models.Content)
+	var slugs []string
+	for _, page := range s.Pages {
+		if page.Series == nil {
+			continue
+		}
+		slug := utils.Urlize(page.Series.Name)
+		if slug == "" {
+			s.Warnings = append(s.Warnings, &FileError{Path: s.SourcePath(page), Err: fmt.Errorf("series %q has no usable characters", page.Series.Name)})
+			page.Series = nil
+			continue
+		}
+		if _, ok := bySlug[slug]; !ok {
+			slugs = append(slugs, slug)
+		}
+		bySlug[slug] = append(bySlug[slug], page)
+	}
+	if len(slugs) == 0 {
+		return nil
+	}
+	sort.Strings(slugs)
+
+	sc := s.Config.Series
+	template := orDefault(sc.Template, "series")
+	if utils.Templates.Lookup(template) == nil {
+		return []error{&FileError{Path: "config.json", Err: fmt.Errorf("series: template %s not found", template)}}
+	}
+
+	var errs []error
+	for _, slug := range slugs {
+		parts := models.ContentList(bySlug[slug])
+		sort.SliceStable(parts, func(i, j int) bool {
+			a, b := parts[i].Series.Order, parts[j].Series.Order
+			if (a == 0) != (b == 0) {
+				return a != 0
+			}
+			if a != b {
+				return a < b
+			}
+			return parts[i].Date.Before(parts[j].Date)
+		})
+
+		name := parts[0].Series.Name
+		permalink := cleanPermalink(s.Config, strings.ReplaceAll(orDefault(sc.Permalink, "/series/:series"), ":series", slug))
+		for i, page := range parts {
+			if i > 0 && page.Series.Order != 0 && page.Series.Order == parts[i-1].Series.Order {
+				s.Warnings = append(s.Warnings, &FileError{Path: s.SourcePath(page), Err: fmt.Errorf("series %q: seriesOrder %d is also used by %s", name, page.Series.Order, s.SourcePath(parts[i-1]))})
+			}
+			page.Series.Name, page.Series.Slug, page.Series.Permalink = name, slug, permalink
+			page.Series.Pages, page.Series.Part = parts, i+1
+			page.Series.Prev, page.Series.Next = neighbours(parts, i)
+		}
+
+		route := &Route{Permalink: permalink, Template: template}
+		route.Data = s.PageData(s.generatedPage(name, fmt.Sprintf("A series in %d parts", len(parts)), permalink))
+		route.Data.Series = &models.Series{Name: name, Slug: slug, Permalink: permalink, Pages: parts}
+		if err := s.addRoute(route); err != nil {
+			errs = append(errs, &FileError{Path: s.SourcePath(parts[0]), Err: fmt.Errorf("series %q: %w", name, err)})
+		}
+	}
+	return errs
+}
+
+// linkCollections links every page outside the page collection to the
+// content published just before and after it in the same collection.
+func (s *Site) linkCollections() {
+	byCollection := make(map[string][]*models.Content)
+	for _, page := range s.Pages {
+		if page.Collection != "page" {
+			byCollection[page.Collection] = append(byCollection[page.Collection], page)
+		}
+	}
+	for _, pages := range byCollection {
+		sort.SliceStable(pages, func(i, j int) bool {
+			return pages[i].Date.Before(pages[j].Date)
+		})
+		for i, page := range pages {
+			page.Prev, page.Next = neighbours(pages, i)
+		}
+	}
+}
+
+// neighbours returns references to the items around index i.
+func neighbours(pages []*models.Content, i int) (prev, next *models.Ref) {
+	if i > 0 {
+		prev = &models.Ref{Content: pages[i-1]}
+	}
+	if i < len(pages)-1 {
+		next = &models.Ref{Content: pages[i+1]}
+	}
+	return prev, next
+}
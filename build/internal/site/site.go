@@ -0,0 +1,322 @@
+package site
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"ts-www/build/internal/config"
+	"ts-www/build/internal/frontmatter"
+	"ts-www/build/internal/markdown"
+	"ts-www/build/internal/models"
+	"ts-www/build/internal/shortcode"
+	"ts-www/build/internal/utils"
+)
+
+// Site is the in-memory model of everything needed to render the site. It is
+// loaded once per build so pages never have to go back to the disk.
+type Site struct {
+	Config     *config.Config
+	Data       map[string]interface{}
+	Pages      []*models.Content    // Every renderable page, ordered by source path
+	Feed       []models.Content     // Non-page content sorted by date, newest first
+	Taxonomies map[string]*Taxonomy // Taxonomies declared in the config, by name
+	Routes     []*Route             // Everything to render: content pages, then generated listings
+	Skipped    []Skipped            // Unpublished content left out of the site
+	Warnings   []*FileError         // Problems that don't stop a page from rendering
+	Markdown   *markdown.Renderer   // Renders content bodies with the configured extensions
+
+	routes     map[string]*Route              // Routes by normalised permalink
+	alternates map[string][]Alternate         // Feed links by collection, "" for the site-wide ones
+	paginators map[*models.Content]*Paginator // First page of each paginated collection, by list page
+}
+
+// Route is a single rendered page of the site: either a content page or a
+// listing generated from the content, such as a taxonomy term page. Routes
+// that aren't HTML, such as feeds and the JSON form of a page, are written by
+// Render instead of a template.
+type Route struct {
+	Permalink string
+	Template  string
+	Source    string // Markdown file the route renders, empty for generated listings
+	Data      PageData
+
+	Render func(w io.Writer) error // Writes non-template output
+	Uses   []string                // Globals fields read by Render
+	Inputs interface{}             // Other values Render depends on, such as the site's URLs
+}
+
+// FileError ties an error or warning to the content file it came from.
+type FileError struct {
+	Path   string
+	Line   int // Line in the file the error points at, 0 when it concerns the whole file
+	Column int // Column in the line, 0 when unknown
+	Err    error
+}
+
+func (e *FileError) Error() string {
+	switch {
+	case e.Column > 0:
+		return fmt.Sprintf("%s:%d:%d: %v", e.Path, e.Line, e.Column, e.Err)
+	case e.Line > 0:
+		return fmt.Sprintf("%s:%d: %v", e.Path, e.Line, e.Err)
+	}
+	return e.Path + ": " + e.Err.Error()
+}
+
+// loadError ties an error loading a content file to the file, at the
+// position a front matter error points at.
+func loadError(path string, err error) *FileError {
+	var fmErr *frontmatter.Error
+	if errors.As(err, &fmErr) {
+		return &FileError{Path: path, Line: fmErr.Line, Column: fmErr.Column, Err: fmt.Errorf("%s front matter: %w", fmErr.Format, fmErr.Err)}
+	}
+	return &FileError{Path: path, Err: err}
+}
+
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// Skipped records a content file that was loaded but held back because it is a
+// draft, scheduled for the future or expired.
+type Skipped struct {
+	Path   string
+	Reason error
+}
+
+// PageData is the value passed to templates when rendering a page. Listings
+// generated from the content get a synthesized Page holding their title.
+type PageData struct {
+	Page       *models.Content
+	Data       map[string]interface{}
+	Feed       []models.Content
+	Taxonomies map[string]*Taxonomy
+	Taxonomy   *Taxonomy      // Set on taxonomy list and term pages
+	Term       *Term          // Set on term pages
+	Paginator  *Paginator     // Set on the pages of a paginated collection list
+	Archive    *Archive       // Set on date archive pages
+	Series     *models.Series // Set on series overview pages
+	Alternates []Alternate    // Other formats of the page, such as its feeds
+}
+
+// Alternate describes a <link rel="alternate"> for a page.
+type Alternate struct {
+	Type  string // MIME type, e.g. application/rss+xml
+	Title string
+	Href  string
+}
+
+// Local returns a copy of d without the values shared by every page, leaving
+// only what is particular to the page it renders.
+func (d PageData) Local() PageData {
+	d.Data, d.Feed, d.Taxonomies = nil, nil, nil
+	return d
+}
+
+// Globals returns the template data values shared by every page, keyed by
+// their PageData field name. The data files are left out; they are files on
+// disk and can be tracked as such.
+func (s *Site) Globals() map[string]interface{} {
+	return map[string]interface{}{
+		"Feed":       s.Feed,
+		"Taxonomies": s.Taxonomies,
+	}
+}
+
+// Load reads the data directory and every markdown file in the content
+// directory. Unpublished content is skipped unless opts asks for it, and
+// base.md files are never rendered since they only hold the front matter
+// template for new content. Pages that fail to load are left out of the site
+// and their *FileError errors are joined into the returned error, so callers
+// can report every broken file at once and still render the rest.
+func Load(cfg *config.Config, opts utils.PublishOptions) (*Site, error) {
+	data, err := utils.LoadData(cfg.DataPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load data: %w", err)
+	}
+
+	s := &Site{Config: cfg, Data: data, routes: make(map[string]*Route)}
+
+	paths, err := contentPaths(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	errs := s.checkOutputs()
+	s.Markdown, err = markdown.New(cfg.Markdown)
+	if err != nil {
+		errs = append(errs, &FileError{Path: "config.json", Err: err})
+	}
+	for _, path := range paths {
+		page, err := utils.LoadPage(path, cfg)
+		if err != nil {
+			errs = append(errs, loadError(path, err))
+			continue
+		}
+		if err := utils.CheckPublished(page, opts); err != nil {
+			s.Skipped = append(s.Skipped, Skipped{Path: path, Reason: err})
+			continue
+		}
+		// Dates that don't parse are warned about unless the schema rejects them
+		schemaErrs := checkSchema(cfg, path, page)
+		for _, err := range schemaErrs {
+			errs = append(errs, err)
+		}
+		s.Warnings = append(s.Warnings, checkDates(path, page, schemaErrs)...)
+		s.dropUnusableTerms(page, path)
+
+		page.Permalink, err = Permalink(cfg, page)
+		if err != nil {
+			errs = append(errs, &FileError{Path: path, Err: err})
+			continue
+		}
+		if err := s.addPageRoutes(page, path); err != nil {
+			errs = append(errs, &FileError{Path: path, Err: err})
+			continue
+		}
+		if page.URL == "" {
+			page.URL = page.Permalink // Only external links need a hand-written url
+		}
+		s.Pages = append(s.Pages, page)
+	}
+	if cfg.Dates.Lastmod == "git" {
+		if err := s.gitLastmod(); err != nil {
+			s.Warnings = append(s.Warnings, &FileError{Path: cfg.ContentPath, Err: err})
+		}
+	}
+
+	// Internal links and shortcodes are expanded once every page is loaded,
+	// so they can refer to any of them. Links go first: they keep the lines of
+	// the body intact for the line numbers of later errors
+	links := s.newLinkResolver()
+	for _, page := range s.Pages {
+		body, linkErrs, linkWarnings := links.rewrite(page)
+		errs = append(errs, linkErrs...)
+		s.Warnings = append(s.Warnings, linkWarnings...)
+		page.Body = body
+	}
+	expander := &shortcode.Expander{Templates: utils.Shortcodes, Ref: links.Ref}
+	for _, page := range s.Pages {
+		path := s.SourcePath(page)
+		body, shortcodeErrs := expander.Expand(page, page.Body)
+		for _, err := range shortcodeErrs {
+			var se *shortcode.Error
+			if errors.As(err, &se) {
+				errs = append(errs, &FileError{Path: path, Line: page.BodyLine + se.Line - 1, Err: se.Err})
+			}
+		}
+		page.Body = body
+		rendered := s.Markdown.Render(page.Body)
+		for _, w := range rendered.Warnings {
+			s.Warnings = append(s.Warnings, &FileError{Path: path, Line: page.BodyLine + w.Line - 1, Err: w.Err})
+		}
+		page.HTML = rendered.HTML
+		page.TOC = rendered.TOC
+		s.summarize(page, rendered)
+
+		// The feed holds every collection item except pages
+		if page.Collection != "page" {
+			if page.Date.IsZero() && len(page.DateErrors) == 0 {
+				s.Warnings = append(s.Warnings, &FileError{Path: path, Err: errors.New("date is missing, so it is listed last")})
+			}
+			s.Feed = append(s.Feed, *page)
+		}
+	}
+	utils.SortFeed(s.Feed)
+	s.buildRelated()
+	s.linkCollections()
+
+	errs = append(errs, s.buildFeeds()...)
+	errs = append(errs, s.buildTaxonomies()...)
+	errs = append(errs, s.buildPagination()...)
+	errs = append(errs, s.buildArchives()...)
+	errs = append(errs, s.buildSeries()...)
+	errs = append(errs, s.buildSearch()...)
+	errs = append(errs, s.buildSitemap()...) // Lists the routes, so it comes last
+
+	// Content routes were registered while loading; their data can only be
+	// filled in now that every page and listing is known
+	for _, route := range s.Routes {
+		if route.Source != "" && route.Render == nil {
+			route.Data = s.PageData(route.Data.Page)
+		}
+	}
+
+	return s, errors.Join(errs...)
+}
+
+// contentPaths returns the markdown files in the content directory, sorted,
+// leaving out the base.md front matter templates.
+func contentPaths(cfg *config.Config) ([]string, error) {
+	var paths []string
+	err := filepath.Walk(cfg.ContentPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && filepath.Ext(path) == ".md" && info.Name() != "base.md" {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk content directory: %w", err)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// addRoute registers a route, refusing permalinks that are already taken.
+func (s *Site) addRoute(route *Route) error {
+	key := routeKey(route.Permalink)
+	if other, ok := s.routes[key]; ok {
+		owner := other.Source
+		if owner == "" {
+			owner = "a generated page"
+		}
+		return fmt.Errorf("permalink %s is already used by %s", route.Permalink, owner)
+	}
+	s.routes[key] = route
+	s.Routes = append(s.Routes, route)
+	return nil
+}
+
+// PageData builds the template data for a single page of the site.
+func (s *Site) PageData(page *models.Content) PageData {
+	return PageData{
+		Page:       page,
+		Data:       s.Data,
+		Feed:       s.Feed,
+		Taxonomies: s.Taxonomies,
+		Alternates: append(s.alternatesFor(page), s.pageAlternates(page)...),
+		Paginator:  s.paginators[page],
+	}
+}
+
+// generatedPage synthesizes the Page of a generated listing.
+func (s *Site) generatedPage(title, description, permalink string) *models.Content {
+	return &models.Content{
+		Title:       title,
+		Description: description,
+		Permalink:   permalink,
+		URL:         permalink,
+		Theme:       s.Config.ThemeName,
+	}
+}
+
+// TemplateFor returns the name of the template a page is rendered with: the
+// template named after its collection, or "page" when there is none.
+func TemplateFor(page *models.Content) string {
+	if utils.Templates.Lookup(page.Collection) == nil {
+		return "page"
+	}
+	return page.Collection
+}
+
+// SourcePath returns the path of a page's markdown file as seen from the
+// working directory, matching the paths used in errors and warnings.
+func (s *Site) SourcePath(page *models.Content) string {
+	return filepath.ToSlash(filepath.Join(s.Config.ContentPath, page.File))
+}
@@ -0,0 +1,159 @@
+package site
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+	"ts-www/build/internal/sitemap"
+)
+
+// buildSitemap registers sitemap.xml and robots.txt when the config enables
+// them. The sitemap lists every HTML route registered so far, so it has to be
+// built after all other routes. Once there are more URLs than fit in one
+// file, sitemap.xml becomes an index of numbered sitemaps.
+func (s *Site) buildSitemap() []error {
+	sc, rc := s.Config.Sitemap, s.Config.Robots
+	if !sc.Enabled && !rc.Enabled {
+		return nil
+	}
+	if s.Config.BaseURL == "" {
+		return []error{&FileError{Path: "config.json", Err: fmt.Errorf("sitemaps need a baseURL for their absolute links")}}
+	}
+
+	var errs []error
+	if sc.Enabled {
+		urls, err := s.sitemapURLs()
+		if err != nil {
+			return []error{&FileError{Path: "config.json", Err: err}}
+		}
+
+		size := sc.MaxURLs
+		if size <= 0 || size > sitemap.MaxURLs {
+			size = sitemap.MaxURLs
+		}
+		if len(urls) <= size {
+			errs = append(errs, s.addSitemapRoute("/sitemap.xml", urls, sitemap.WriteURLSet))
+		} else {
+			var index []sitemap.URL
+			for i := 0; i*size < len(urls); i++ {
+				part := urls[i*size : min((i+1)*size, len(urls))]
+				permalink := fmt.Sprintf("/sitemap-%d.xml", i+1)
+				errs = append(errs, s.addSitemapRoute(permalink, part, sitemap.WriteURLSet))
+				index = append(index, sitemap.URL{Loc: AbsURL(s.Config, permalink), LastMod: newest(part)})
+			}
+			errs = append(errs, s.addSitemapRoute("/sitemap.xml", index, sitemap.WriteIndex))
+		}
+	}
+
+	if rc.Enabled {
+		route := &Route{
+			Permalink: "/robots.txt",
+			Data:      PageData{Page: s.generatedPage("robots.txt", "", "/robots.txt")},
+			Render:    s.writeRobots,
+		}
+		if err := s.addRoute(route); err != nil {
+			errs = append(errs, &FileError{Path: "config.json", Err: fmt.Errorf("robots.txt: %w", err)})
+		}
+	}
+	return errs
+}
+
+func (s *Site) addSitemapRoute(permalink string, urls []sitemap.URL, write func(io.Writer, []sitemap.URL) error) error {
+	route := &Route{
+		Permalink: permalink,
+		Data:      PageData{Page: s.generatedPage("Sitemap", "", permalink)},
+		Render:    func(w io.Writer) error { return write(w, urls) },
+		Inputs:    urls,
+	}
+	if err := s.addRoute(route); err != nil {
+		return &FileError{Path: "config.json", Err: fmt.Errorf("sitemap: %w", err)}
+	}
+	return nil
+}
+
+// sitemapURLs lists the HTML routes worth crawling. Content that is only
+// rendered as a preview, pages marked unlisted and permalinks matching the
+// configured exclusions are left out. Content pages are dated by their
+// lastmod front matter, falling back to their date.
+func (s *Site) sitemapURLs() ([]sitemap.URL, error) {
+	for _, pattern := range s.Config.Sitemap.Exclude {
+		if _, err := path.Match(pattern, "/"); err != nil {
+			return nil, fmt.Errorf("sitemap exclude pattern %q: %w", pattern, err)
+		}
+	}
+
+	var urls []sitemap.URL
+	for _, route := range s.Routes {
+		page := route.Data.Page
+		if route.Render != nil || !page.Published() || page.Unlisted || s.excluded(route.Permalink) {
+			continue
+		}
+		u := sitemap.URL{Loc: AbsURL(s.Config, route.Permalink)}
+		if route.Source != "" {
+			u.LastMod = page.Lastmod
+			if u.LastMod.IsZero() {
+				u.LastMod = page.Date
+			}
+		}
+		urls = append(urls, u)
+	}
+	return urls, nil
+}
+
+// excluded reports whether a permalink matches one of the sitemap's exclude
+// patterns, with or without its trailing slash.
+func (s *Site) excluded(permalink string) bool {
+	trimmed := strings.TrimSuffix(permalink, "/")
+	for _, pattern := range s.Config.Sitemap.Exclude {
+		if ok, _ := path.Match(pattern, permalink); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, trimmed); ok && trimmed != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// writeRobots writes robots.txt from the configured rules, allowing every
+// crawler everything when there are none.
+func (s *Site) writeRobots(w io.Writer) error {
+	var b strings.Builder
+	rules := s.Config.Robots.Rules
+	if len(rules) == 0 {
+		b.WriteString("User-agent: *\nDisallow:\n")
+	}
+	for i, rule := range rules {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "User-agent: %s\n", orDefault(rule.UserAgent, "*"))
+		for _, p := range rule.Allow {
+			fmt.Fprintf(&b, "Allow: %s\n", p)
+		}
+		for _, p := range rule.Disallow {
+			fmt.Fprintf(&b, "Disallow: %s\n", p)
+		}
+		if len(rule.Allow) == 0 && len(rule.Disallow) == 0 {
+			b.WriteString("Disallow:\n")
+		}
+	}
+	if s.Config.Sitemap.Enabled {
+		fmt.Fprintf(&b, "\nSitemap: %s\n", AbsURL(s.Config, "/sitemap.xml"))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// newest returns the most recent modification date among urls.
+func newest(urls []sitemap.URL) time.Time {
+	var t time.Time
+	for _, u := range urls {
+		if u.LastMod.After(t) {
+			t = u.LastMod
+		}
+	}
+	return t
+}
@@ -0,0 +1,56 @@
+package site
+
+import (
+	"bytes"
+	"html"
+	"html/template"
+	"strings"
+	"ts-www/build/internal/markdown"
+	"ts-www/build/internal/models"
+	"ts-www/build/internal/search"
+	"unicode"
+)
+
+// moreMarker ends the summary of a body written by hand.
+const moreMarker = "<!--more-->"
+
+// countWords counts the fields of a text that are words rather than runs of
+// punctuation.
+func countWords(words []string) int {
+	n := 0
+	for _, word := range words {
+		if strings.IndexFunc(word, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) >= 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// summarize sets the summary, word count and reading time of a page from its
+// rendered body.
+func (s *Site) summarize(page *models.Content, rendered markdown.Result) {
+	words := strings.Fields(search.PlainText(string(rendered.HTML)))
+	page.WordCount = countWords(words)
+	if page.WordCount > 0 {
+		wpm := s.Config.Summary.WordsPerMinute
+		if wpm <= 0 {
+			wpm = 200
+		}
+		page.ReadingTime = (page.WordCount + wpm - 1) / wpm
+	}
+
+	if before, after, found := bytes.Cut(page.Body, []byte(moreMarker)); found {
+		page.Summary = s.Markdown.Render(before).HTML
+		page.Truncated = len(bytes.TrimSpace(after)) > 0
+		return
+	}
+
+	n := s.Config.Summary.Words
+	if n <= 0 {
+		n = 70
+	}
+	if len(words) > n {
+		words, page.Truncated = words[:n], true
+	}
+	page.Summary = template.HTML(html.EscapeString(strings.Join(words, " ")))
+}
@@ -0,0 +1,150 @@
+package site
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"ts-www/build/internal/models"
+	"ts-www/build/internal/utils"
+)
+
+// Taxonomy groups the site's content by the terms of one front matter key.
+type Taxonomy struct {
+	Name      string
+	Permalink string
+	Terms     []*Term // Sorted by name
+
+	terms map[string]*Term // By slug
+}
+
+// Term is a single value of a taxonomy and the content that carries it.
+type Term struct {
+	Name      string
+	Slug      string
+	Permalink string
+	Pages     []*models.Content // Sorted by date, newest first
+}
+
+// Term returns the term with the given name, or nil if no content uses it.
+func (t *Taxonomy) Term(name string) *Term {
+	if t == nil {
+		return nil
+	}
+	return t.terms[utils.Urlize(name)]
+}
+
+// buildTaxonomies groups the loaded pages into the taxonomies declared in the
+// config and registers a list page for each taxonomy and a page per term.
+func (s *Site) buildTaxonomies() []error {
+	s.Taxonomies = make(map[string]*Taxonomy)
+
+	names := make([]string, 0, len(s.Config.Taxonomies))
+	for name := range s.Config.Taxonomies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		tc := s.Config.Taxonomies[name]
+		taxonomy := &Taxonomy{
+			Name:      name,
+			Permalink: cleanPermalink(s.Config, expandTaxonomy(orDefault(tc.Permalink, "/:taxonomy"), name, "")),
+			terms:     make(map[string]*Term),
+		}
+		s.Taxonomies[name] = taxonomy
+
+		for _, page := range s.Pages {
+			for _, termName := range page.Terms[name] {
+				slug := utils.Urlize(termName)
+				term, ok := taxonomy.terms[slug]
+				if !ok {
+					term = &Term{
+						Name:      termName,
+						Slug:      slug,
+						Permalink: cleanPermalink(s.Config, expandTaxonomy(orDefault(tc.TermPermalink, "/:taxonomy/:term"), name, slug)),
+					}
+					taxonomy.terms[slug] = term
+					taxonomy.Terms = append(taxonomy.Terms, term)
+				}
+				term.Pages = append(term.Pages, page)
+			}
+		}
+		sort.Slice(taxonomy.Terms, func(i, j int) bool { return taxonomy.Terms[i].Slug < taxonomy.Terms[j].Slug })
+
+		listTemplate := orDefault(tc.Template, "taxonomy")
+		termTemplate := orDefault(tc.TermTemplate, "term")
+		for _, tmpl := range []string{listTemplate, termTemplate} {
+			if utils.Templates.Lookup(tmpl) == nil {
+				errs = append(errs, &FileError{Path: "config.json", Err: fmt.Errorf("taxonomy %s: template %s not found", name, tmpl)})
+			}
+		}
+
+		route := &Route{Permalink: taxonomy.Permalink, Template: listTemplate}
+		route.Data = s.PageData(s.generatedPage(name, fmt.Sprintf("All %s", name), taxonomy.Permalink))
+		route.Data.Taxonomy = taxonomy
+		if err := s.addRoute(route); err != nil {
+			errs = append(errs, &FileError{Path: "config.json", Err: fmt.Errorf("taxonomy %s: %w", name, err)})
+		}
+
+		for _, term := range taxonomy.Terms {
+			sortByDate(term.Pages)
+			route := &Route{Permalink: term.Permalink, Template: termTemplate}
+			route.Data = s.PageData(s.generatedPage(term.Name, fmt.Sprintf("Content in %s %s", name, term.Name), term.Permalink))
+			route.Data.Taxonomy = taxonomy
+			route.Data.Term = term
+			if err := s.addRoute(route); err != nil {
+				errs = append(errs, &FileError{Path: "config.json", Err: fmt.Errorf("taxonomy %s: %w", name, err)})
+			}
+		}
+	}
+
+	return errs
+}
+
+// dropUnusableTerms removes the terms of a page that have no characters to
+// build a permalink from, such as "!!!", warning about each, so templates only
+// see terms that have a page.
+func (s *Site) dropUnusableTerms(page *models.Content, path string) {
+	names := make([]string, 0, len(page.Terms))
+	for name := range page.Terms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		var usable []string
+		for _, termName := range page.Terms[name] {
+			if utils.Urlize(termName) == "" {
+				s.Warnings = append(s.Warnings, &FileError{Path: path, Line: page.KeyLines[name], Err: fmt.Errorf("%s term %q has no usable characters", name, termName)})
+				continue
+			}
+			usable = append(usable, termName)
+		}
+		if len(usable) == 0 {
+			delete(page.Terms, name)
+		} else {
+			page.Terms[name] = usable
+		}
+	}
+	page.Tags = page.Terms["tags"]
+}
+
+// expandTaxonomy fills the :taxonomy and :term tokens of a permalink pattern.
+func expandTaxonomy(pattern, taxonomy, term string) string {
+	return strings.NewReplacer(":taxonomy", taxonomy, ":term", term).Replace(pattern)
+}
+
+// sortByDate sorts pages like the feed: newest first, keeping the order of
+// pages that share a date.
+func sortByDate(pages []*models.Content) {
+	sort.SliceStable(pages, func(i, j int) bool {
+		return pages[i].Date.After(pages[j].Date)
+	})
+}
+
+func orDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,34 @@
+package site
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"ts-www/build/internal/config"
+	"ts-www/build/internal/models"
+)
+
+func TestDropUnusableTerms(t *testing.T) {
+	s := &Site{Config: &config.Config{}}
+	page := &models.Content{
+		Terms:    map[string][]string{"tags": {"go", "!!!", "Static Sites"}, "topics": {"???"}},
+		KeyLines: map[string]int{"tags": 5, "topics": 6},
+	}
+	s.dropUnusableTerms(page, "content/writing/intro.md")
+
+	want := map[string][]string{"tags": {"go", "Static Sites"}}
+	if !reflect.DeepEqual(page.Terms, want) {
+		t.Errorf("Terms = %v, want %v", page.Terms, want)
+	}
+	if !reflect.DeepEqual(page.Tags, want["tags"]) {
+		t.Errorf("Tags = %v, want %v", page.Tags, want["tags"])
+	}
+	if len(s.Warnings) != 2 {
+		t.Fatalf("Warnings = %v, want two", s.Warnings)
+	}
+	for i, line := range []int{5, 6} {
+		if w := s.Warnings[i]; w.Line != line || !strings.Contains(w.Error(), "no usable characters") {
+			t.Errorf("warning %v, want one on line %d about unusable characters", w, line)
+		}
+	}
+}
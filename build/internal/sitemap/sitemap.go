@@ -0,0 +1,75 @@
+package sitemap
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+// MaxURLs is the number of URLs the sitemap protocol allows in one file.
+const MaxURLs = 50000
+
+const namespace = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+// URL is a single page listed in a sitemap.
+type URL struct {
+	Loc     string    // Absolute URL of the page
+	LastMod time.Time // Zero when unknown
+}
+
+type urlSet struct {
+	XMLName xml.Name   `xml:"urlset"`
+	NS      string     `xml:"xmlns,attr"`
+	URLs    []entryXML `xml:"url"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name   `xml:"sitemapindex"`
+	NS       string     `xml:"xmlns,attr"`
+	Sitemaps []entryXML `xml:"sitemap"`
+}
+
+type entryXML struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// WriteURLSet writes a sitemap listing urls.
+func WriteURLSet(w io.Writer, urls []URL) error {
+	doc := urlSet{NS: namespace}
+	for _, u := range urls {
+		doc.URLs = append(doc.URLs, entry(u))
+	}
+	return writeXML(w, doc)
+}
+
+// WriteIndex writes a sitemap index pointing at the sitemaps at the given
+// locations, each dated by its most recently modified page.
+func WriteIndex(w io.Writer, sitemaps []URL) error {
+	doc := sitemapIndex{NS: namespace}
+	for _, u := range sitemaps {
+		doc.Sitemaps = append(doc.Sitemaps, entry(u))
+	}
+	return writeXML(w, doc)
+}
+
+func entry(u URL) entryXML {
+	e := entryXML{Loc: u.Loc}
+	if !u.LastMod.IsZero() {
+		e.LastMod = u.LastMod.Format(time.RFC3339) // A W3C datetime, keeping the time and its zone
+	}
+	return e
+}
+
+func writeXML(w io.Writer, doc interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
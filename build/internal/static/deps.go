@@ -0,0 +1,81 @@
+package static
+
+import (
+	"text/template/parse"
+	"ts-www/build/internal/utils"
+)
+
+// templateFields returns the names of every field referenced by the named
+// template and the templates it invokes. It over-approximates: a field used
+// on any value, not just the template data, is reported. That is enough to
+// tell whether a page can possibly depend on a part of its template data.
+func templateFields(name string) map[string]bool {
+	fields := make(map[string]bool)
+	visited := make(map[string]bool)
+
+	var walk func(node parse.Node)
+	visit := func(name string) {
+		if visited[name] {
+			return
+		}
+		visited[name] = true
+		if tmpl := utils.Templates.Lookup(name); tmpl != nil && tmpl.Tree != nil {
+			walk(tmpl.Tree.Root)
+		}
+	}
+	walk = func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, child := range n.Nodes {
+				walk(child)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.IfNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.WithNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.TemplateNode:
+			walk(n.Pipe)
+			visit(n.Name)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, cmd := range n.Cmds {
+				walk(cmd)
+			}
+		case *parse.CommandNode:
+			for _, arg := range n.Args {
+				walk(arg)
+			}
+		case *parse.ChainNode:
+			walk(n.Node)
+			for _, field := range n.Field {
+				fields[field] = true
+			}
+		case *parse.FieldNode:
+			for _, field := range n.Ident {
+				fields[field] = true
+			}
+		case *parse.VariableNode:
+			for _, field := range n.Ident[1:] {
+				fields[field] = true
+			}
+		}
+	}
+
+	visit(name)
+	return fields
+}
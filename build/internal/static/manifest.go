@@ -0,0 +1,123 @@
+package static
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+)
+
+// manifestName is the file in the output directory that records what the
+// previous build read and wrote.
+const manifestName = ".build-manifest.json"
+
+// manifestVersion is bumped whenever the generator changes in a way that makes
+// earlier outputs unusable, forcing a full rebuild.
+const manifestVersion = 1
+
+// globalInput names the virtual input standing for a template data value
+// shared by every page, such as the assembled content feed.
+func globalInput(field string) string {
+	return "@" + field
+}
+
+// manifest maps every input of a build to its content hash and every output
+// to the inputs it was rendered from. Paths use forward slashes; outputs are
+// relative to the output directory.
+type manifest struct {
+	Version int                 `json:"version"`
+	Inputs  map[string]string   `json:"inputs"`
+	Outputs map[string][]string `json:"outputs"`
+}
+
+func newManifest() *manifest {
+	return &manifest{
+		Version: manifestVersion,
+		Inputs:  make(map[string]string),
+		Outputs: make(map[string][]string),
+	}
+}
+
+// readManifest loads the manifest left by the previous build. A missing,
+// unreadable or outdated manifest yields nil so everything is rebuilt.
+func readManifest(outputDir string) *manifest {
+	raw, err := os.ReadFile(filepath.Join(outputDir, manifestName))
+	if err != nil {
+		return nil
+	}
+	var m manifest
+	if err := json.Unmarshal(raw, &m); err != nil || m.Version != manifestVersion {
+		return nil
+	}
+	return &m
+}
+
+func (m *manifest) write(outputDir string) error {
+	raw, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(outputDir, manifestName), raw, 0644)
+}
+
+// hashFile records the hash of a file on disk as an input.
+func (m *manifest) hashFile(path string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	m.Inputs[filepath.ToSlash(filepath.Clean(path))] = hashBytes(raw)
+	return nil
+}
+
+// hashFiles records every path as an input and returns their keys in sorted
+// order.
+func (m *manifest) hashFiles(paths []string) ([]string, error) {
+	keys := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if err := m.hashFile(path); err != nil {
+			return nil, err
+		}
+		keys = append(keys, filepath.ToSlash(filepath.Clean(path)))
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// hashValue records the JSON encoding of v as the input key. It is used for
+// inputs that exist only in memory, such as the feed or a loaded page.
+func (m *manifest) hashValue(key string, v interface{}) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	m.Inputs[key] = hashBytes(raw)
+	return nil
+}
+
+// upToDate reports whether output can be reused: it must still exist and have
+// been rendered from the same inputs, all of which are unchanged in next.
+func (m *manifest) upToDate(next *manifest, output, outputDir string) bool {
+	if m == nil {
+		return false
+	}
+	prevDeps, ok := m.Outputs[output]
+	if !ok || !slices.Equal(prevDeps, next.Outputs[output]) {
+		return false
+	}
+	for _, dep := range prevDeps {
+		if m.Inputs[dep] == "" || m.Inputs[dep] != next.Inputs[dep] {
+			return false
+		}
+	}
+	_, err := os.Stat(filepath.Join(outputDir, filepath.FromSlash(output)))
+	return err == nil
+}
+
+func hashBytes(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
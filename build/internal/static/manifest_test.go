@@ -0,0 +1,154 @@
+package static
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// testSite writes a small site into a temporary directory and makes it the
+// working directory for the rest of the test, since builds read their inputs
+// from there.
+func testSite(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	writeFiles(t, dir, "assets/js/site.js")
+	files := map[string]string{
+		"config.json": `{
+			"siteTitle": "Test",
+			"contentPath": "./content/",
+			"outputPath": "./out/",
+			"themeName": "test",
+			"dataPath": "./data/",
+			"baseURL": "https://example.com",
+			"permalinks": {"page": "/:slug", "writing": "/writing/:slug"}
+		}`,
+		"data/links.json":          `[]`,
+		"themes/test.css":          "body {}",
+		"templates/page.html":      `{{ define "page" }}{{ .Page.Title }} {{ .Page.HTML }}{{ end }}`,
+		"templates/writing.html":   `{{ define "writing" }}<h1>{{ .Page.Title }}</h1>{{ .Page.HTML }}{{ end }}`,
+		"content/page/about.md":    "---\ntitle: About\n---\nRead [[hello]].\n",
+		"content/page/contact.md":  "---\ntitle: Contact\n---\nMail me.\n",
+		"content/writing/hello.md": "---\ntitle: Hello\ndescription: First\ndate: 2024-01-02\n---\nHi.\n",
+		"content/writing/other.md": "---\ntitle: Other\ndescription: Second\ndate: 2024-02-03\n---\nMore.\n",
+	}
+	for name, content := range files {
+		writeFiles(t, dir, name)
+		edit(t, filepath.Join(dir, filepath.FromSlash(name)), content)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+// build runs an incremental build and returns the outputs it rendered.
+func build(t *testing.T) []string {
+	t.Helper()
+	report, err := BuildSite(Options{Incremental: true})
+	if err != nil {
+		t.Fatalf("build failed: %v", err)
+	}
+	sort.Strings(report.Rendered)
+	return report.Rendered
+}
+
+func edit(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIncrementalBuild(t *testing.T) {
+	testSite(t)
+	all := []string{"about.html", "contact.html", "writing/hello.html", "writing/other.html"}
+	if got := build(t); !reflect.DeepEqual(got, all) {
+		t.Fatalf("first build rendered %v, want %v", got, all)
+	}
+
+	// Each step edits the tree left by the one before and builds again
+	steps := []struct {
+		name string
+		path string
+		edit string
+		want []string
+	}{
+		{"nothing changed", "", "", []string{}},
+		{"template edited", "templates/writing.html", `{{ define "writing" }}<h2>{{ .Page.Title }}</h2>{{ .Page.HTML }}{{ end }}`, all},
+		{"config edited", "config.json", `{"siteTitle": "Renamed", "contentPath": "./content/", "outputPath": "./out/", "themeName": "test", "dataPath": "./data/", "baseURL": "https://example.com", "permalinks": {"page": "/:slug", "writing": "/writing/:slug"}}`, all},
+		// The link text on the about page and the neighbour of the other
+		// post are the title of the edited one
+		{"linked page edited", "content/writing/hello.md", "---\ntitle: Hello again\ndescription: First\ndate: 2024-01-02\n---\nHi.\n", []string{"about.html", "writing/hello.html", "writing/other.html"}},
+		{"page body edited", "content/page/contact.md", "---\ntitle: Contact\n---\nWrite to me.\n", []string{"contact.html"}},
+		// No template reads the data files
+		{"unused data edited", "data/links.json", `[{"title": "x"}]`, []string{}},
+		{"nothing changed since", "", "", []string{}},
+	}
+	for _, step := range steps {
+		if step.path != "" {
+			edit(t, step.path, step.edit)
+		}
+		if got := build(t); !reflect.DeepEqual(got, step.want) {
+			t.Errorf("%s: rendered %v, want %v", step.name, got, step.want)
+		}
+	}
+}
+
+func TestIncrementalBuildRestoresDeletedOutput(t *testing.T) {
+	testSite(t)
+	build(t)
+	if err := os.Remove(filepath.Join("out", "contact.html")); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := build(t), []string{"contact.html"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("rendered %v, want %v", got, want)
+	}
+}
+
+func TestUpToDate(t *testing.T) {
+	out := t.TempDir()
+	writeFiles(t, out, "a.html")
+	prev := newManifest()
+	prev.Inputs["content/a.md"] = "1"
+	prev.Inputs["config.json"] = "1"
+	prev.Outputs["a.html"] = []string{"content/a.md", "config.json"}
+
+	with := func(inputs map[string]string, deps ...string) *manifest {
+		m := newManifest()
+		for k, v := range inputs {
+			m.Inputs[k] = v
+		}
+		m.Outputs["a.html"] = deps
+		return m
+	}
+	same := map[string]string{"content/a.md": "1", "config.json": "1"}
+	tests := []struct {
+		name string
+		prev *manifest
+		next *manifest
+		want bool
+	}{
+		{"unchanged", prev, with(same, "content/a.md", "config.json"), true},
+		{"no previous build", nil, with(same, "content/a.md", "config.json"), false},
+		{"input changed", prev, with(map[string]string{"content/a.md": "2", "config.json": "1"}, "content/a.md", "config.json"), false},
+		{"input gone", prev, with(map[string]string{"content/a.md": "1"}, "content/a.md", "config.json"), false},
+		{"new dependency", prev, with(map[string]string{"content/a.md": "1", "config.json": "1", "@Feed": "1"}, "content/a.md", "config.json", "@Feed"), false},
+		{"failed last time", with(same), with(same, "content/a.md", "config.json"), false},
+	}
+	for _, tt := range tests {
+		if got := tt.prev.upToDate(tt.next, "a.html", out); got != tt.want {
+			t.Errorf("%s: upToDate = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if prev.upToDate(with(same, "content/a.md", "config.json"), "a.html", t.TempDir()) {
+		t.Error("upToDate with the output missing = true, want false")
+	}
+}
@@ -0,0 +1,115 @@
+package static
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// pruneOutputs deletes every output the previous build wrote that the current
+// build no longer produces, such as the HTML of deleted or renamed content.
+// Only files recorded in the previous manifest are touched. It returns the
+// removed paths relative to the output directory.
+func pruneOutputs(prev, next *manifest, outputDir string) ([]string, error) {
+	if prev == nil {
+		return nil, nil
+	}
+
+	var stale []string
+	for output := range prev.Outputs {
+		if _, ok := next.Outputs[output]; !ok {
+			stale = append(stale, output)
+		}
+	}
+	sort.Strings(stale)
+
+	var removed []string
+	var errs []error
+	for _, output := range stale {
+		path := filepath.Join(outputDir, filepath.FromSlash(output))
+		if !within(outputDir, path) {
+			continue // A manifest edited by hand mustn't reach outside
+		}
+		if err := os.Remove(path); err != nil {
+			if !os.IsNotExist(err) {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		removed = append(removed, output)
+		removeEmptyParents(filepath.Dir(path), outputDir)
+	}
+
+	return removed, errors.Join(errs...)
+}
+
+// removeEmptyParents removes dir and its ancestors up to, but not including,
+// root for as long as they are empty.
+func removeEmptyParents(dir, root string) {
+	root = filepath.Clean(root)
+	for dir = filepath.Clean(dir); dir != root && within(root, dir); dir = filepath.Dir(dir) {
+		if err := os.Remove(dir); err != nil {
+			return // Not empty, or not ours to remove
+		}
+	}
+}
+
+// cleanOutputDir wipes the output directory so the site can be regenerated
+// from scratch. It returns the removed files relative to the output directory.
+func cleanOutputDir(outputDir string) ([]string, error) {
+	if err := checkCleanable(outputDir); err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	err := filepath.Walk(outputDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			relativePath, err := filepath.Rel(outputDir, path)
+			if err != nil {
+				return err
+			}
+			removed = append(removed, filepath.ToSlash(relativePath))
+		}
+		return nil
+	})
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if err := os.RemoveAll(outputDir); err != nil {
+		return nil, err
+	}
+	return removed, nil
+}
+
+// checkCleanable refuses to wipe an output directory that contains the
+// working directory, which would take the site's sources with it.
+func checkCleanable(outputDir string) error {
+	absOutput, err := filepath.Abs(outputDir)
+	if err != nil {
+		return err
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	if within(absOutput, wd) {
+		return fmt.Errorf("refusing to clean output directory %s: it contains the working directory", outputDir)
+	}
+	return nil
+}
+
+// within reports whether path is root or lies inside it.
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
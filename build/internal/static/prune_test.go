@@ -0,0 +1,167 @@
+package static
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeFiles creates each file, relative to dir, with its parents.
+func writeFiles(t *testing.T, dir string, files ...string) {
+	t.Helper()
+	for _, file := range files {
+		path := filepath.Join(dir, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(file), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestPruneRenamedSource(t *testing.T) {
+	out := t.TempDir()
+	writeFiles(t, out, "index.html", "writing/old-name/index.html", "writing/new-name/index.html", "writing/old-name.json", "notes.txt")
+	prev := newManifest()
+	prev.Outputs["index.html"] = []string{"content/page/index.md"}
+	prev.Outputs["writing/old-name/index.html"] = []string{"content/writing/old-name.md"}
+	prev.Outputs["writing/old-name.json"] = []string{"content/writing/old-name.md"}
+	prev.Outputs["writing/gone/index.html"] = []string{"content/writing/gone.md"} // Already deleted by hand
+	next := newManifest()
+	next.Outputs["index.html"] = []string{"content/page/index.md"}
+	next.Outputs["writing/new-name/index.html"] = []string{"content/writing/new-name.md"}
+
+	removed, err := pruneOutputs(prev, next, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"writing/old-name.json", "writing/old-name/index.html"}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("removed %v, want %v", removed, want)
+	}
+	for _, gone := range []string{"writing/old-name/index.html", "writing/old-name"} {
+		if exists(filepath.Join(out, gone)) {
+			t.Errorf("%s is still there", gone)
+		}
+	}
+	// Files the manifest doesn't list are never pruned
+	for _, kept := range []string{"index.html", "writing/new-name/index.html", "notes.txt"} {
+		if !exists(filepath.Join(out, kept)) {
+			t.Errorf("%s was removed", kept)
+		}
+	}
+}
+
+func TestPruneWithoutPreviousManifest(t *testing.T) {
+	out := t.TempDir()
+	writeFiles(t, out, "index.html")
+	removed, err := pruneOutputs(nil, newManifest(), out)
+	if err != nil || removed != nil {
+		t.Errorf("pruneOutputs = %v, %v, want nothing", removed, err)
+	}
+	if !exists(filepath.Join(out, "index.html")) {
+		t.Error("index.html was removed")
+	}
+}
+
+func TestPruneStaysInOutputDir(t *testing.T) {
+	root := t.TempDir()
+	out := filepath.Join(root, "public")
+	writeFiles(t, root, "outside.txt", "public/a/b/page.html", "public2/x/page.html")
+	if err := os.MkdirAll(filepath.Join(root, "public2", "empty"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	prev := newManifest()
+	prev.Outputs["../outside.txt"] = nil
+	prev.Outputs["../public2/x/page.html"] = nil
+	prev.Outputs["a/b/page.html"] = nil
+
+	removed, err := pruneOutputs(prev, newManifest(), out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a/b/page.html"}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("removed %v, want %v", removed, want)
+	}
+	// Emptied directories go, up to but not including the output directory
+	if exists(filepath.Join(out, "a")) {
+		t.Error("public/a is still there")
+	}
+	for _, kept := range []string{"public", "outside.txt", "public2/x/page.html"} {
+		if !exists(filepath.Join(root, kept)) {
+			t.Errorf("%s was removed", kept)
+		}
+	}
+
+	// A sibling sharing the output directory's name as a prefix isn't inside it
+	removeEmptyParents(filepath.Join(root, "public2", "empty"), out)
+	if !exists(filepath.Join(root, "public2", "empty")) {
+		t.Error("removeEmptyParents removed public2/empty, outside public")
+	}
+}
+
+func TestRemoveEmptyParents(t *testing.T) {
+	out := t.TempDir()
+	writeFiles(t, out, "a/kept.html")
+	deep := filepath.Join(out, "a", "b", "c")
+	if err := os.MkdirAll(deep, 0755); err != nil {
+		t.Fatal(err)
+	}
+	removeEmptyParents(deep, out)
+	if exists(filepath.Join(out, "a", "b")) {
+		t.Error("a/b is still there")
+	}
+	if !exists(filepath.Join(out, "a", "kept.html")) {
+		t.Error("a/kept.html was removed")
+	}
+
+	empty := t.TempDir()
+	removeEmptyParents(empty, empty)
+	if !exists(empty) {
+		t.Error("the root was removed")
+	}
+}
+
+func TestCleanOutputDir(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "public")
+	writeFiles(t, out, "index.html", "writing/post/index.html")
+	removed, err := cleanOutputDir(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"index.html", "writing/post/index.html"}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("removed %v, want %v", removed, want)
+	}
+	if exists(out) {
+		t.Error("the output directory is still there")
+	}
+
+	removed, err = cleanOutputDir(out)
+	if err != nil || removed != nil {
+		t.Errorf("cleaning a missing directory = %v, %v, want nothing", removed, err)
+	}
+}
+
+func TestCheckCleanable(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{".", "..", wd, filepath.Dir(wd), "/"} {
+		if err := checkCleanable(dir); err == nil || !strings.Contains(err.Error(), "working directory") {
+			t.Errorf("checkCleanable(%q) = %v, want a refusal", dir, err)
+		}
+	}
+	for _, dir := range []string{t.TempDir(), "testdata-missing", wd + "-sibling"} {
+		if err := checkCleanable(dir); err != nil {
+			t.Errorf("checkCleanable(%q) = %v, want none", dir, err)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package static
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+	"ts-www/build/internal/site"
+)
+
+// ErrorKind classifies build failures so callers can react to them, for
+// example with distinct exit codes.
+type ErrorKind string
+
+const (
+	ConfigError   ErrorKind = "config"   // The configuration could not be loaded
+	ContentError  ErrorKind = "content"  // A content or data file could not be loaded
+	TemplateError ErrorKind = "template" // Templates failed to parse or execute
+	OutputError   ErrorKind = "output"   // Writing to the output directory failed
+)
+
+// Exit codes returned by the build command. Code 2 is left to the flag
+// package, which uses it for invalid arguments.
+const (
+	ExitOK       = 0
+	ExitOutput   = 1
+	ExitConfig   = 3
+	ExitContent  = 4
+	ExitTemplate = 5
+	ExitWarnings = 6
+)
+
+// BuildError is a failure of one kind, optionally tied to a source file.
+type BuildError struct {
+	Kind   ErrorKind
+	File   string
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *BuildError) Error() string {
+	if e.File == "" {
+		return fmt.Sprintf("%s error: %v", e.Kind, e.Err)
+	}
+	return fmt.Sprintf("%s error: %s: %v", e.Kind, location(e.File, e.Line, e.Column), e.Err)
+}
+
+// location formats a position as "file:line:column", leaving out what is
+// unknown.
+func location(file string, line, column int) string {
+	switch {
+	case column > 0:
+		return fmt.Sprintf("%s:%d:%d", file, line, column)
+	case line > 0:
+		return fmt.Sprintf("%s:%d", file, line)
+	}
+	return file
+}
+
+func (e *BuildError) Unwrap() error {
+	return e.Err
+}
+
+// Report describes what a build did. It is returned by BuildSite even when
+// the build fails, and can be printed as text or JSON.
+type Report struct {
+	Rendered  []string      `json:"rendered"`  // Outputs written by this build
+	Unchanged []string      `json:"unchanged"` // Outputs reused from the previous build
+	Skipped   []ReportEntry `json:"skipped"`   // Unpublished content left out
+	Removed   []string      `json:"removed"`   // Stale or cleaned outputs deleted
+	Warnings  []ReportEntry `json:"warnings"`
+	Errors    []ReportError `json:"errors"`
+	Phases    []Phase       `json:"phases"`
+	Duration  Duration      `json:"duration"`
+
+	errs []error
+}
+
+func newReport() *Report {
+	return &Report{
+		Rendered:  []string{},
+		Unchanged: []string{},
+		Skipped:   []ReportEntry{},
+		Removed:   []string{},
+		Warnings:  []ReportEntry{},
+		Errors:    []ReportError{},
+		Phases:    []Phase{},
+	}
+}
+
+// ReportEntry is a message about a single file.
+type ReportEntry struct {
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+}
+
+// ReportError is a failure recorded in a report.
+type ReportError struct {
+	Kind    ErrorKind `json:"kind"`
+	File    string    `json:"file,omitempty"`
+	Line    int       `json:"line,omitempty"`
+	Column  int       `json:"column,omitempty"`
+	Message string    `json:"message"`
+}
+
+// Phase is the time spent in one step of the build.
+type Phase struct {
+	Name     string   `json:"name"`
+	Duration Duration `json:"duration"`
+}
+
+// Duration marshals to JSON as fractional milliseconds.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(float64(d) / float64(time.Millisecond))
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).Round(time.Microsecond).String()
+}
+
+// phase records the time elapsed since start under name.
+func (r *Report) phase(name string, start time.Time) {
+	r.Phases = append(r.Phases, Phase{Name: name, Duration: Duration(time.Since(start))})
+}
+
+func (r *Report) warn(file string, line int, message string) {
+	r.Warnings = append(r.Warnings, ReportEntry{File: file, Line: line, Message: message})
+}
+
+// fail records err under kind. Joined errors are recorded one by one, and
+// errors that already carry a kind or a file keep them.
+func (r *Report) fail(kind ErrorKind, err error) {
+	if err == nil {
+		return
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			r.fail(kind, e)
+		}
+		return
+	}
+
+	var buildErr *BuildError
+	if !errors.As(err, &buildErr) {
+		buildErr = &BuildError{Kind: kind, Err: err}
+		var fileErr *site.FileError
+		if errors.As(err, &fileErr) {
+			buildErr.File, buildErr.Line, buildErr.Column, buildErr.Err = fileErr.Path, fileErr.Line, fileErr.Column, fileErr.Err
+			// Loading the site reports settings it can't use against the
+			// config, and those are config failures
+			if kind == ContentError && fileErr.Path == "config.json" {
+				buildErr.Kind = ConfigError
+			}
+		}
+	}
+	r.errs = append(r.errs, buildErr)
+	r.Errors = append(r.Errors, ReportError{Kind: buildErr.Kind, File: buildErr.File, Line: buildErr.Line, Column: buildErr.Column, Message: buildErr.Err.Error()})
+}
+
+// Err joins the recorded errors, or returns nil if the build succeeded.
+func (r *Report) Err() error {
+	return errors.Join(r.errs...)
+}
+
+// ExitCode returns the process exit code for the build. Configuration
+// failures take precedence over template failures, which take precedence
+// over content and output failures.
+func (r *Report) ExitCode(failOnWarnings bool) int {
+	code := ExitOK
+	rank := map[int]int{ExitOK: 0, ExitWarnings: 1, ExitOutput: 2, ExitContent: 3, ExitTemplate: 4, ExitConfig: 5}
+	raise := func(c int) {
+		if rank[c] > rank[code] {
+			code = c
+		}
+	}
+	if failOnWarnings && len(r.Warnings) > 0 {
+		raise(ExitWarnings)
+	}
+	for _, e := range r.Errors {
+		switch e.Kind {
+		case ConfigError:
+			raise(ExitConfig)
+		case TemplateError:
+			raise(ExitTemplate)
+		case ContentError:
+			raise(ExitContent)
+		default:
+			raise(ExitOutput)
+		}
+	}
+	return code
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteText writes a human readable summary of the report.
+func (r *Report) WriteText(w io.Writer) error {
+	var err error
+	printf := func(format string, args ...interface{}) {
+		if err == nil {
+			_, err = fmt.Fprintf(w, format, args...)
+		}
+	}
+
+	printf("Rendered %d pages, %d unchanged, %d removed in %s\n", len(r.Rendered), len(r.Unchanged), len(r.Removed), r.Duration)
+	for _, output := range r.Rendered {
+		printf("  rendered  %s\n", output)
+	}
+	for _, output := range r.Removed {
+		printf("  removed   %s\n", output)
+	}
+	for _, s := range r.Skipped {
+		printf("  skipped   %s: %s\n", s.File, s.Message)
+	}
+	for _, warning := range r.Warnings {
+		if warning.File == "" {
+			printf("  warning   %s\n", warning.Message)
+		} else {
+			printf("  warning   %s: %s\n", location(warning.File, warning.Line, 0), warning.Message)
+		}
+	}
+	for _, e := range r.Errors {
+		if e.File == "" {
+			printf("  error     [%s] %s\n", e.Kind, e.Message)
+		} else {
+			printf("  error     [%s] %s: %s\n", e.Kind, location(e.File, e.Line, e.Column), e.Message)
+		}
+	}
+	printf("Phases:\n")
+	for _, p := range r.Phases {
+		printf("  %-10s %s\n", p.Name, p.Duration)
+	}
+	return err
+}
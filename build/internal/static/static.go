@@ -1,32 +1,83 @@
 package static
 
 import (
-	"log"
+	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
 	"ts-www/build/internal/config"
-	"ts-www/build/internal/models"
+	"ts-www/build/internal/markdown"
+	"ts-www/build/internal/site"
 	"ts-www/build/internal/utils"
 )
 
-func init() {
-	err := utils.LoadTemplates()
-	if err != nil {
-		log.Fatalf("Failed to load templates: %v", err)
-	}
+// Options control how BuildSite treats the output of previous builds.
+type Options struct {
+	Incremental bool // Re-render only pages whose inputs changed since the last build
+	Force       bool // Ignore the build manifest and re-render every page
+	Clean       bool // Wipe the output directory before building
+	Publish     utils.PublishOptions
 }
 
-// BuildSite generates static HTML files from Markdown content
-func BuildSite() {
+// renderJob describes a single route to be written to the output directory.
+type renderJob struct {
+	route      *site.Route
+	output     string // Output path relative to the output directory, slash separated
+	outputPath string
+}
+
+// BuildSite generates static HTML files from Markdown content. The whole site
+// is loaded once and pages are rendered concurrently; every failure is
+// collected into the returned report rather than stopping at the first one.
+// The error joins all recorded failures as *BuildError values.
+func BuildSite(opts Options) (*Report, error) {
+	report := newReport()
+	started := time.Now()
+	defer func() { report.Duration = Duration(time.Since(started)) }()
+
+	start := time.Now()
 	cfg, err := config.LoadConfig("./config.json")
 	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+		report.fail(ConfigError, fmt.Errorf("failed to load config: %w", err))
+		return report, report.Err()
+	}
+	if err := utils.LoadTemplates(site.FuncMap(cfg)); err != nil {
+		report.fail(TemplateError, err)
+		return report, report.Err()
 	}
 
-	data, err := utils.LoadData(cfg.DataPath)
-	if err != nil {
-		log.Fatalf("Failed to load data: %v", err)
+	s, err := site.Load(cfg, opts.Publish)
+	report.fail(ContentError, err)
+	if s == nil {
+		return report, report.Err()
+	}
+	for _, skipped := range s.Skipped {
+		report.Skipped = append(report.Skipped, ReportEntry{File: skipped.Path, Message: skipped.Reason.Error()})
+	}
+	for _, warning := range s.Warnings {
+		report.warn(warning.Path, warning.Line, warning.Err.Error())
+	}
+	report.phase("load", start)
+
+	start = time.Now()
+	outputDir := cfg.OutputPath
+
+	// The previous manifest records which files in the output directory
+	// belong to the build, so it is read even when nothing is reused
+	prev := readManifest(outputDir)
+	reuse := opts.Incremental && !opts.Force
+
+	if opts.Clean {
+		removed, err := cleanOutputDir(outputDir)
+		if err != nil {
+			report.fail(OutputError, fmt.Errorf("failed to clean output directory: %w", err))
+			return report, report.Err()
+		}
+		report.Removed = append(report.Removed, removed...)
+		prev = nil
 	}
 
 	// Copy the theme CSS to the assets/css directory
@@ -35,11 +86,13 @@ func BuildSite() {
 	os.MkdirAll(filepath.Dir(assetsCSSPath), os.ModePerm) // Create the assets/css directory if it doesn't exist
 	err = utils.CopyFile(themeCSSPath, assetsCSSPath)
 	if err != nil {
-		log.Fatalf("Failed to copy theme CSS to assets directory: %v", err)
+		report.fail(ConfigError, fmt.Errorf("failed to copy theme CSS to assets directory: %w", err))
+		return report, report.Err()
+	}
+	if err := markdown.WriteStyleSheet(cfg.Markdown, "assets"); err != nil {
+		report.fail(OutputError, fmt.Errorf("failed to write syntax highlighting CSS: %w", err))
+		return report, report.Err()
 	}
-
-	contentDir := cfg.ContentPath
-	outputDir := cfg.OutputPath
 
 	os.MkdirAll(outputDir, os.ModePerm)
 
@@ -48,107 +101,232 @@ func BuildSite() {
 	assetsDst := filepath.Join(outputDir, "public")
 	err = utils.CopyDir(assetsSrc, assetsDst)
 	if err != nil {
-		log.Fatalf("Failed to copy assets directory: %v", err)
+		report.fail(OutputError, fmt.Errorf("failed to copy assets directory: %w", err))
+		return report, report.Err()
 	}
+	report.phase("assets", start)
 
-	// Iterate through all Markdown files in the content directory
-	err = filepath.Walk(contentDir, func(path string, info os.FileInfo, err error) error {
-		if filepath.Ext(path) == ".md" {
-			return generateHTML(path, outputDir, data, cfg) // Pass the loaded data and cfg
-		}
-		return nil
-	})
+	start = time.Now()
+	next, err := buildManifest(s, assetsSrc, themeCSSPath)
 	if err != nil {
-		log.Fatalf("Error building site: %v", err)
+		report.fail(OutputError, fmt.Errorf("failed to hash build inputs: %w", err))
+		return report, report.Err()
 	}
 
-	log.Println("Site built successfully")
-}
+	var jobs []renderJob
+	for _, route := range s.Routes {
+		output := filepath.ToSlash(route.OutputPath(s.Config.URLs))
+		if reuse && prev.upToDate(next, output, outputDir) {
+			report.Unchanged = append(report.Unchanged, output)
+			continue
+		}
+		if page := route.Data.Page; route.Source != "" && route.Render == nil && route.Template != page.Collection {
+			report.warn(route.Source, 0, fmt.Sprintf("template %s not found, using default page", page.Collection))
+		}
+		jobs = append(jobs, renderJob{route: route, output: output, outputPath: filepath.Join(outputDir, output)})
+	}
+	report.phase("plan", start)
 
-func generateHTML(mdPath, outputDir string, data map[string]interface{}, cfg *config.Config) error {
-	// Extract the relative path of the Markdown file from the content directory
-	relativePath, err := filepath.Rel(cfg.ContentPath, mdPath)
-	if err != nil {
-		log.Printf("Error getting relative path: %v", err)
-		return err
+	start = time.Now()
+	renderErrs := renderAll(s, jobs, runtime.NumCPU())
+	for i, err := range renderErrs {
+		if err != nil {
+			report.fail(OutputError, err)
+
+			// Failed outputs stay owned by the build but lose their inputs,
+			// so the next build retries them instead of pruning or reusing them
+			next.Outputs[jobs[i].output] = []string{}
+			continue
+		}
+		report.Rendered = append(report.Rendered, jobs[i].output)
 	}
+	report.phase("render", start)
 
-	// Change the extension from .md to .html
-	htmlPath := strings.TrimSuffix(relativePath, filepath.Ext(relativePath)) + ".html"
+	start = time.Now()
+	pruned, err := pruneOutputs(prev, next, outputDir)
+	report.fail(OutputError, err)
+	report.Removed = append(report.Removed, pruned...)
 
-	// If the file is in the 'page' directory, place it at the root of the output directory
-	if strings.HasPrefix(relativePath, "page"+string(filepath.Separator)) {
-		htmlPath = strings.TrimPrefix(htmlPath, "page"+string(filepath.Separator))
+	if err := next.write(outputDir); err != nil {
+		report.fail(OutputError, fmt.Errorf("failed to write build manifest: %w", err))
 	}
+	report.phase("prune", start)
 
-	// Create the full path for the output HTML file
-	outputPath := filepath.Join(outputDir, htmlPath)
+	return report, report.Err()
+}
 
-	// Create the necessary directories in the output path
-	if err := os.MkdirAll(filepath.Dir(outputPath), os.ModePerm); err != nil {
-		log.Printf("Error creating directories: %v", err)
-		return err
+// buildManifest hashes every input of the build and records which inputs each
+// output is produced from. Every route depends on its own template data, the
+// configuration and the templates; the data files and the values shared by
+// all pages, such as the feed, only count when the route's template
+// references them. Copied assets are recorded too so the build owns
+// them and can prune them once they are gone.
+func buildManifest(s *site.Site, assetsDir, themeCSSPath string) (*manifest, error) {
+	m := newManifest()
+
+	err := filepath.Walk(assetsDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		relativePath, err := filepath.Rel(assetsDir, path)
+		if err != nil {
+			return err
+		}
+		source, err := m.hashFiles([]string{path})
+		if err != nil {
+			return err
+		}
+		m.Outputs["public/"+filepath.ToSlash(relativePath)] = source
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	templateFiles, err := filepath.Glob("templates/*.html")
+	if err != nil {
+		return nil, err
+	}
+	shortcodeFiles, err := filepath.Glob("templates/shortcodes/*.html")
+	if err != nil {
+		return nil, err
+	}
+	templateFiles = append(templateFiles, shortcodeFiles...)
+	shared, err := m.hashFiles(append([]string{"config.json"}, templateFiles...))
+	if err != nil {
+		return nil, err
 	}
 
-	page, err := utils.LoadPageFromDirectory(filepath.Dir(mdPath)+"/", filepath.Base(mdPath))
+	var dataFiles []string
+	err = filepath.Walk(s.Config.DataPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if filepath.Ext(path) == ".json" {
+			dataFiles = append(dataFiles, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	data, err := m.hashFiles(dataFiles)
 	if err != nil {
-		if err == utils.ErrDraftContent {
-			log.Printf("Skipping draft content: %s", mdPath)
-			return nil // Skip this draft content without error
+		return nil, err
+	}
+	for field, value := range s.Globals() {
+		if err := m.hashValue(globalInput(field), value); err != nil {
+			return nil, err
 		}
-		log.Printf("Error loading page: %v", err)
-		return err // Actual error, return it
 	}
 
-	// Generate the OG Image URL
-	// ogImageFileName := strings.TrimSuffix(filepath.Base(outputPath), filepath.Ext(outputPath)) + "-og-image.png"
-	// ogImageUrl := "/public/og-image/" + ogImageFileName
-	// page.OGImageURL = ogImageUrl
+	theme, err := m.hashFiles([]string{themeCSSPath})
+	if err != nil {
+		return nil, err
+	}
+	m.Outputs["public/css/"+filepath.Base(themeCSSPath)] = theme // Copied from the theme, not the assets
 
-	// Determine template based on the collection (parent directory name)
-	collection := filepath.Base(filepath.Dir(mdPath))
-	tmplName := collection
+	fieldsByTemplate := make(map[string]map[string]bool)
+	for _, route := range s.Routes {
+		fields, ok := fieldsByTemplate[route.Template]
+		if route.Render != nil {
+			fields = make(map[string]bool)
+			for _, field := range route.Uses {
+				fields[field] = true
+			}
+		} else if !ok {
+			fields = templateFields(route.Template)
+			fieldsByTemplate[route.Template] = fields
+		}
 
-	// Use the collection's template; default to "page.html" if not found
-	tmpl := utils.Templates.Lookup(tmplName + ".html")
-	if tmpl == nil {
-		log.Printf("Template %s.html not found, using default page.html", tmplName)
-		tmpl = utils.Templates.Lookup("page.html")
+		// Routes are hashed as loaded rather than as files, so a change in
+		// publish state re-renders them even when the source is untouched.
+		// Other formats of a page share its source and are keyed by permalink
+		key := route.Source
+		if key == "" || route.Render != nil {
+			key = "@route:" + route.Permalink
+		}
+		var value interface{} = route.Data.Local()
+		if route.Inputs != nil {
+			value = []interface{}{value, route.Inputs}
+		}
+		if err := m.hashValue(key, value); err != nil {
+			return nil, err
+		}
+		deps := append([]string{key}, shared...)
+		if fields["Data"] {
+			deps = append(deps, data...)
+		}
+		for field := range s.Globals() {
+			if fields[field] {
+				deps = append(deps, globalInput(field))
+			}
+		}
+		sort.Strings(deps[1+len(shared):])
+		m.Outputs[filepath.ToSlash(route.OutputPath(s.Config.URLs))] = deps
 	}
 
-	feed, err := utils.LoadFeed(cfg.ContentPath)
-	if err != nil {
-		log.Println("Failed to load feed:", err)
+	return m, nil
+}
+
+// renderAll renders jobs on a bounded pool of workers. The returned errors
+// line up with jobs so repeated builds report failures identically.
+func renderAll(s *site.Site, jobs []renderJob, workers int) []error {
+	if workers < 1 {
+		workers = 1
 	}
 
-	newdata, err := utils.LoadData(cfg.DataPath)
-	if err != nil {
-		log.Printf("Failed to load data: %v", err)
+	errs := make([]error, len(jobs))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				errs[i] = generateHTML(s, jobs[i])
+			}
+		}()
 	}
+	for i := range jobs {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
 
-	log.Printf("Executing template with Page: %+v", page)
+	return errs
+}
 
-	templateData := struct {
-		Page *models.Content
-		Data map[string]interface{}
-		Feed []models.Content
-	}{
-		Page: page,
-		Data: newdata,
-		Feed: feed,
+func generateHTML(s *site.Site, job renderJob) error {
+	route := job.route
+	file := route.Source
+	if file == "" {
+		file = route.Permalink
 	}
 
-	outputFile, err := os.Create(outputPath)
+	// Create the necessary directories in the output path
+	if err := os.MkdirAll(filepath.Dir(job.outputPath), os.ModePerm); err != nil {
+		return &BuildError{Kind: OutputError, File: file, Err: fmt.Errorf("error creating directories: %w", err)}
+	}
+
+	// Generate the OG Image URL
+	// ogImageFileName := strings.TrimSuffix(filepath.Base(outputPath), filepath.Ext(outputPath)) + "-og-image.png"
+	// ogImageUrl := "/public/og-image/" + ogImageFileName
+	// page.OGImageURL = ogImageUrl
+
+	outputFile, err := os.Create(job.outputPath)
 	if err != nil {
-		log.Printf("Error creating file: %v", err)
-		return err
+		return &BuildError{Kind: OutputError, File: file, Err: fmt.Errorf("error creating file: %w", err)}
 	}
 	defer outputFile.Close()
 
-	err = tmpl.ExecuteTemplate(outputFile, tmplName, templateData)
+	if route.Render != nil {
+		err = route.Render(outputFile)
+	} else {
+		err = utils.Templates.ExecuteTemplate(outputFile, route.Template, route.Data)
+	}
 	if err != nil {
-		log.Printf("Error rendering template: %v", err)
-		return err
+		return &BuildError{Kind: TemplateError, File: file, Err: fmt.Errorf("error rendering %s: %w", job.output, err)}
 	}
 
 	return nil
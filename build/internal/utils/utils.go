@@ -15,70 +15,62 @@ import (
 	"strings"
 	"time"
 	"ts-www/build/internal/config"
+	"ts-www/build/internal/frontmatter"
 	"ts-www/build/internal/models"
+	"unicode"
 
-	"github.com/go-yaml/yaml"
 	"github.com/russross/blackfriday/v2"
 )
 
-func LoadFeed(directory string) ([]models.Content, error) {
-	var allContent []models.Content
-
-	err := filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if info.IsDir() || filepath.Ext(path) != ".md" {
-			return nil // Skip directories and non-markdown files.
-		}
-
-		// Extract collection name and file name.
-		collectionName := filepath.Base(filepath.Dir(path))
-		fileName := filepath.Base(path)
-
-		// Skip if the collection is "page" or the file is "base.md".
-		if collectionName == "page" || fileName == "base.md" {
-			return nil
-		}
-
-		// Correctly construct the path to include the collection
-		collectionDir := filepath.Dir(path)
-
-		// Use LoadPageFromDirectory to load the content, passing the correct directory and file name.
-		content, err := LoadPageFromDirectory(collectionDir+"/", fileName)
-		if err != nil {
-			log.Printf("Error loading content from %s: %v", path, err)
-			return nil // Continue processing other files even if one fails.
-		}
-
-		// Append loaded content to the slice.
-		allContent = append(allContent, *content)
-
-		return nil
+// SortFeed sorts content items by date, newest first, with undated items
+// last. The sort is stable so items sharing a date keep the order they were
+// loaded in.
+func SortFeed(items []models.Content) {
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Date.After(items[j].Date)
 	})
+}
 
-	if err != nil {
-		return nil, err
-	}
+var (
+	ErrDraftContent   = errors.New("content is marked as draft")
+	ErrFutureContent  = errors.New("content has a publish date in the future")
+	ErrExpiredContent = errors.New("content has passed its expiry date")
+)
 
-	// Sort the slice by date.
-	sort.Slice(allContent, func(i, j int) bool {
-		dateI, errI := time.Parse("2006-01-02", allContent[i].Date)
-		dateJ, errJ := time.Parse("2006-01-02", allContent[j].Date)
-		if errI != nil || errJ != nil {
-			log.Printf("Error parsing date: %v, %v", errI, errJ)
-			return false
-		}
-		return dateI.After(dateJ)
-	})
+// PublishOptions select which unpublished content is loaded anyway, for
+// example to preview drafts locally.
+type PublishOptions struct {
+	Drafts  bool // Include content marked as draft
+	Future  bool // Include content whose publish date has not arrived yet
+	Expired bool // Include content whose expiry date has passed
+}
 
-	return allContent, nil
+// CheckPublished returns nil if the content should be rendered under opts, or
+// the error describing why it is held back.
+func CheckPublished(content *models.Content, opts PublishOptions) error {
+	switch {
+	case content.Draft && !opts.Drafts:
+		return ErrDraftContent
+	case content.Future && !opts.Future:
+		return ErrFutureContent
+	case content.Expired && !opts.Expired:
+		return ErrExpiredContent
+	}
+	return nil
 }
 
-var ErrDraftContent = errors.New("content is marked as draft")
+// frontMatterFields are the front matter keys LoadPage reads into fields of
+// the content; every other key except taxonomies ends up in its Params.
+var frontMatterFields = map[string]bool{
+	"title": true, "description": true, "date": true, "lastmod": true, "unlisted": true, "draft": true,
+	"publishDate": true, "expiryDate": true, "featured": true, "url": true, "slug": true, "series": true,
+	"seriesOrder": true, "data-title": true, "data-description": true, "data-image": true,
+}
 
-func LoadPageFromDirectory(directory, title string) (*models.Content, error) {
-	filename := directory + title
+// LoadPage reads a single markdown file and converts its front matter and body
+// into a content item using an already loaded configuration. Unpublished
+// content is loaded too; its state is recorded on the item for CheckPublished.
+func LoadPage(filename string, cfg *config.Config) (*models.Content, error) {
 	content, err := os.ReadFile(filename)
 	if err != nil {
 		return nil, err
@@ -89,32 +81,75 @@ func LoadPageFromDirectory(directory, title string) (*models.Content, error) {
 		return nil, err
 	}
 
-	cfg, err := config.LoadConfig("./config.json") // Load configuration
-	if err != nil {
-		return nil, err
-	}
-
 	var contentItem models.Content
 	contentItem.Title, _ = frontMatter["title"].(string)
-	contentItem.Date, _ = frontMatter["date"].(string)
+	// Dates that don't parse are left zero and reported by the caller, so a
+	// draft can hold a placeholder until it is published
+	loc := cfg.Dates.Location()
+	for _, date := range []struct {
+		key   string
+		field *time.Time
+	}{
+		{"date", &contentItem.Date},
+		{"lastmod", &contentItem.Lastmod},
+		{"publishDate", &contentItem.PublishDate},
+		{"expiryDate", &contentItem.ExpiryDate},
+	} {
+		var err error
+		if *date.field, err = frontMatterDate(frontMatter, date.key, loc); err != nil {
+			contentItem.DateErrors = append(contentItem.DateErrors, err)
+		}
+	}
+	contentItem.Unlisted, _ = frontMatter["unlisted"].(bool)
 	if description, ok := frontMatter["description"].(string); ok {
 		contentItem.Description = description
 	} else {
 		contentItem.Description = ""
 	}
-	// Check if the content is marked as a draft
-	if draft, ok := frontMatter["draft"].(bool); ok && draft {
-		return nil, ErrDraftContent
+	// Record whether the content is a draft, scheduled or expired
+	contentItem.Draft, _ = frontMatter["draft"].(bool)
+	publishDate := contentItem.PublishDate
+	if publishDate.IsZero() {
+		publishDate = contentItem.Date
+	}
+	now := Now()
+	if publishDate.After(now) {
+		contentItem.Future = true
+	}
+	if t := contentItem.ExpiryDate; !t.IsZero() && !t.After(now) {
+		contentItem.Expired = true
 	}
 	if featured, ok := frontMatter["featured"].(bool); ok {
 		contentItem.Featured = featured
-	} else {
-		contentItem.Draft = true
 	}
 	contentItem.Body = body
+	contentItem.BodyLine = bytes.Count(content[:len(content)-len(body)], []byte("\n")) + 1
+	contentItem.FrontMatter = frontMatter
+	contentItem.KeyLines = frontmatter.KeyLines(content)
 	contentItem.URL, _ = frontMatter["url"].(string)
 	contentItem.Theme = cfg.ThemeName // Assuming the theme is consistent across all content
 	contentItem.Collection = filepath.Base(filepath.Dir(filename))
+	if relativePath, err := filepath.Rel(cfg.ContentPath, filename); err == nil {
+		contentItem.File = filepath.ToSlash(relativePath)
+	}
+	for taxonomy := range cfg.Taxonomies {
+		if terms := stringList(frontMatter[taxonomy]); len(terms) > 0 {
+			if contentItem.Terms == nil {
+				contentItem.Terms = make(map[string][]string)
+			}
+			contentItem.Terms[taxonomy] = terms
+		}
+	}
+	contentItem.Tags = contentItem.Terms["tags"]
+	if name, ok := frontMatter["series"].(string); ok && name != "" {
+		contentItem.Series = &models.Series{Name: name}
+		contentItem.Series.Order, _ = frontMatter["seriesOrder"].(int)
+	}
+	if slug, ok := frontMatter["slug"].(string); ok && slug != "" {
+		contentItem.Slug = slug
+	} else {
+		contentItem.Slug = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	}
 	if DataTitle, ok := frontMatter["data-title"].(string); ok {
 		contentItem.DataTitle = DataTitle
 	} else {
@@ -130,10 +165,91 @@ func LoadPageFromDirectory(directory, title string) (*models.Content, error) {
 	} else {
 		contentItem.DataImage = ""
 	}
+	for key, value := range frontMatter {
+		if _, taxonomy := cfg.Taxonomies[key]; frontMatterFields[key] || taxonomy {
+			continue
+		}
+		if contentItem.Params == nil {
+			contentItem.Params = make(map[string]interface{})
+		}
+		contentItem.Params[key] = value
+	}
 
 	return &contentItem, nil
 }
 
+// frontMatterDate reads a date of the front matter, the zero time when it is
+// missing or left blank.
+func frontMatterDate(frontMatter map[string]interface{}, key string, loc *time.Location) (time.Time, error) {
+	value, ok := frontMatter[key]
+	if !ok || value == nil || value == "" {
+		return time.Time{}, nil
+	}
+	t, err := ParseTime(fmt.Sprint(value), loc)
+	if err != nil {
+		return time.Time{}, &DateError{Key: key, Err: err}
+	}
+	return t, nil
+}
+
+// DateError is a front matter date that doesn't parse.
+type DateError struct {
+	Key string // Front matter key of the date
+	Err error
+}
+
+func (e *DateError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Key, e.Err)
+}
+
+func (e *DateError) Unwrap() error {
+	return e.Err
+}
+
+// stringList reads a front matter value holding either a single string or a
+// list of strings, skipping empty and duplicate entries.
+func stringList(value interface{}) []string {
+	var items []interface{}
+	switch v := value.(type) {
+	case string:
+		items = []interface{}{v}
+	case []interface{}:
+		items = v
+	}
+
+	var list []string
+	seen := make(map[string]bool)
+	for _, item := range items {
+		s := strings.TrimSpace(fmt.Sprint(item))
+		if s == "" || seen[s] {
+			continue
+		}
+		seen[s] = true
+		list = append(list, s)
+	}
+	return list
+}
+
+// Urlize turns a name into a lowercase, hyphen separated URL segment, e.g.
+// "Static Sites" into "static-sites".
+func Urlize(name string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			hyphen = false
+			b.WriteRune(r)
+		default:
+			hyphen = true
+		}
+	}
+	return b.String()
+}
+
 func LoadData(directory string) (map[string]interface{}, error) {
 	data := make(map[string]interface{})
 	err := filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
@@ -167,12 +283,35 @@ func MarkDowner(args ...interface{}) template.HTML {
 
 var Templates *template.Template
 
-func LoadTemplates() error {
+// Shortcodes holds the templates in templates/shortcodes, named after their
+// files. They are kept apart from the page templates so a shortcode can share
+// a name with a page template.
+var Shortcodes *template.Template
+
+// LoadTemplates parses every template, and the shortcode templates, with the
+// built-in functions plus any extra functions supplied by the caller.
+func LoadTemplates(funcs ...template.FuncMap) error {
+	funcMap := template.FuncMap{"markDown": MarkDowner, "parseDate": ParseDate, "now": Now}
+	for _, extra := range funcs {
+		for name, fn := range extra {
+			funcMap[name] = fn
+		}
+	}
+
 	var err error
-	Templates, err = template.New("").Funcs(template.FuncMap{"markDown": MarkDowner, "parseDate": ParseDate, "now": Now}).ParseGlob("templates/*.html")
+	Templates, err = template.New("").Funcs(funcMap).ParseGlob("templates/*.html")
 	if err != nil {
 		return fmt.Errorf("error loading templates: %w", err)
 	}
+
+	Shortcodes = template.New("").Funcs(funcMap)
+	shortcodeFiles, err := filepath.Glob("templates/shortcodes/*.html")
+	if err != nil || len(shortcodeFiles) == 0 {
+		return err
+	}
+	if Shortcodes, err = Shortcodes.ParseFiles(shortcodeFiles...); err != nil {
+		return fmt.Errorf("error loading shortcode templates: %w", err)
+	}
 	return nil
 }
 
@@ -208,29 +347,11 @@ func RenderTemplateDev(w http.ResponseWriter, tmpl string, content interface{})
 	}
 }
 
+// ParseFrontMatter splits a content file into its YAML, TOML or JSON front
+// matter and its body. Errors are *frontmatter.Error values with the line and
+// column of the problem.
 func ParseFrontMatter(content []byte) (map[string]interface{}, []byte, error) {
-	frontMatter := make(map[string]interface{})
-	var contentStart int
-
-	delimiter := []byte("---")
-	start := bytes.Index(content, delimiter)
-	if start == -1 {
-		return nil, nil, errors.New("Front matter delimiter not found")
-	}
-
-	end := bytes.Index(content[start+len(delimiter):], delimiter)
-	if end == -1 {
-		return nil, nil, errors.New("Second front matter delimiter not found")
-	}
-
-	if err := yaml.Unmarshal(content[start+len(delimiter):start+len(delimiter)+end], &frontMatter); err != nil {
-		return nil, nil, err
-	}
-
-	contentStart = start + len(delimiter) + end + len(delimiter)
-	actualContent := content[contentStart:]
-
-	return frontMatter, actualContent, nil
+	return frontmatter.Parse(content)
 }
 
 func CopyFile(src, dst string) error {
@@ -378,13 +499,26 @@ func ConvertMarkdownToJSON(contentDir, dataDir string) error {
 	return nil
 }
 
-// ParseDate parses a date string in "YYYY-MM-DD" format and returns a time.Time object.
-func ParseDate(dateStr string) time.Time {
-	t, err := time.Parse("2006-01-02", dateStr)
-	if err != nil {
-		// Handle the error according to your needs
-		return time.Time{} // return zero time on error
+// DateLayouts are the layouts content dates may be written in. Only RFC 3339
+// timestamps carry a time zone.
+var DateLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"}
+
+// ParseTime parses a date in any of DateLayouts. Dates without a time zone
+// are in loc.
+func ParseTime(value string, loc *time.Location) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range DateLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
 	}
+	return time.Time{}, fmt.Errorf("%q is not a date such as 2006-01-02 or an RFC 3339 timestamp", value)
+}
+
+// ParseDate parses a date in any of DateLayouts, in UTC unless it has a time
+// zone of its own, and returns the zero time when it doesn't parse.
+func ParseDate(dateStr string) time.Time {
+	t, _ := ParseTime(dateStr, time.UTC)
 	return t
 }
 